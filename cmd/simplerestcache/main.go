@@ -15,7 +15,16 @@ import (
 	group "github.com/oklog/run"
 
 	service "simpleRestCache/pkg/service"
-	storage "simpleRestCache/pkg/storage/gorm"
+	"simpleRestCache/pkg/storage/bolt"
+	"simpleRestCache/pkg/storage/compress"
+	gorm "simpleRestCache/pkg/storage/gorm"
+	"simpleRestCache/pkg/storage/inmem"
+	"simpleRestCache/pkg/storage/redis"
+	"simpleRestCache/pkg/storage/tiered"
+
+	// parsers of endpoint API responses
+	_ "simpleRestCache/pkg/parser/aviasalesru/placesjsonv2"
+	_ "simpleRestCache/pkg/parser/passthrough"
 )
 
 func main() {
@@ -30,10 +39,32 @@ func main() {
 		log.Warn("Debug mode is activated")
 	}
 
-	storage := storage.New(cfg)
+	var storage tiered.Backend
+	switch cfg.Storage {
+	case "inmem":
+		storage = newInmem(cfg)
+	case "redis":
+		storage = redis.New(cfg)
+	case "bolt":
+		s, err := bolt.New(cfg)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot initialize a storage")
+			os.Exit(1)
+		}
+		storage = s
+	default:
+		storage = gorm.New(cfg)
+	}
+	if cfg.Tiered {
+		storage = tiered.New(newInmem(cfg), storage)
+	}
+	storage = compress.New(storage, cfg.Compression)
 	defer storage.Close()
 
 	service := service.New(cfg, storage)
+	defer service.Close()
 	server := server.New(cfg, service)
 	control := control.New(cfg, service)
 
@@ -72,3 +103,11 @@ func main() {
 	}
 	g.Run()
 }
+
+// newInmem returns inmem storage, a sharded one if there are several shards
+func newInmem(cfg *config.Config) tiered.Front {
+	if cfg.InmemShards > 1 {
+		return inmem.NewSharded(cfg)
+	}
+	return inmem.New(cfg)
+}
@@ -16,11 +16,13 @@ type control struct {
 // Handler asks a service and output to a console
 type Handler interface {
 	All()
+	List(f handler.Filter)
 	TopN(n int)
 	LastN(n int)
 	Refresh()
 	Clean()
 	Settings()
+	Stats()
 }
 
 func main() {
@@ -97,6 +99,8 @@ func (c *control) statPath(arr []string) {
 		c.lastPath(arr[1:])
 	case "all":
 		c.allPath(arr[1:])
+	case "storage":
+		c.storagePath(arr[1:])
 	default:
 		c.usageStat()
 		os.Exit(0)
@@ -109,6 +113,7 @@ func (c *control) usageStat() {
 	fmt.Println("\tall\t\tDisplay all from cache")
 	fmt.Println("\ttop <N>\t\tDisplay top <N> popular requests to cache. <N> number")
 	fmt.Println("\tlast <N>\t\tDisplay last <N> unpopular requests to cache. <N> number")
+	fmt.Println("\tstorage\t\tDisplay statistic of a storage")
 }
 
 // =============TOP===============
@@ -153,6 +158,21 @@ func (c *control) usageLast() {
 	fmt.Println("\tDisplay last <N> unpopular requests to cache. <N> number")
 }
 
+// =============STORAGE===============
+func (c *control) storagePath(arr []string) {
+	if len(arr) != 0 {
+		c.usageStorage()
+		os.Exit(0)
+	}
+
+	c.handler.Stats()
+}
+
+func (c *control) usageStorage() {
+	fmt.Println("Usage: \t srcctl stat storage")
+	fmt.Println("\tDisplay statistic of a storage, e.g. size and evictions")
+}
+
 // =============CACHE===============
 func (c *control) cachePath(arr []string) {
 	if len(arr) == 0 {
@@ -162,6 +182,8 @@ func (c *control) cachePath(arr []string) {
 	switch arr[0] {
 	case "all":
 		c.allPath(arr[1:])
+	case "list":
+		c.listPath(arr[1:])
 	case "clean":
 		c.cleanPath(arr[1:])
 	case "refresh":
@@ -176,6 +198,7 @@ func (c *control) usageCache() {
 	fmt.Println("Usage: \t srcctl cache COMMAND")
 	fmt.Println("Commands:")
 	fmt.Println("\tall\t\tDisplay all from cache")
+	fmt.Println("\tlist\t\tDisplay requests from cache selected by filters")
 	fmt.Println("\tclean\t\tDelete all cache records")
 	fmt.Println("\trefresh\t\tRefresh all cache records")
 }
@@ -195,6 +218,42 @@ func (c *control) usageAll() {
 	fmt.Println("\tDisplay all requests stored in cache")
 }
 
+// =============LIST===============
+func (c *control) listPath(arr []string) {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.Usage = c.usageList
+	var (
+		prefix   = fs.String("prefix", "", "")
+		status   = fs.Int("status", 0, "")
+		minAge   = fs.Duration("min-age", 0, "")
+		maxAge   = fs.Duration("max-age", 0, "")
+		minCount = fs.Int("min-count", 0, "")
+	)
+	if err := fs.Parse(arr); err != nil || fs.NArg() != 0 {
+		c.usageList()
+		os.Exit(0)
+	}
+
+	c.handler.List(handler.Filter{
+		Prefix:      *prefix,
+		Status:      *status,
+		MinAge:      *minAge,
+		MaxAge:      *maxAge,
+		MinAskCount: *minCount,
+	})
+}
+
+func (c *control) usageList() {
+	fmt.Println("Usage: \t srcctl cache list [OPTIONS]")
+	fmt.Println("\tDisplay requests stored in cache selected by filters")
+	fmt.Println("Options:")
+	fmt.Println("\t-prefix <path>\t\tRequests which start with <path>")
+	fmt.Println("\t-status <code>\t\tRequests with a status code of a responce")
+	fmt.Println("\t-min-age <duration>\tRequests refreshed earlier then <duration> ago, e.g. 24h")
+	fmt.Println("\t-max-age <duration>\tRequests refreshed during last <duration>, e.g. 1h")
+	fmt.Println("\t-min-count <N>\t\tRequests asked at least <N> times")
+}
+
 // =============REFRESH===============
 func (c *control) refreshPath(arr []string) {
 	if len(arr) != 0 {
@@ -1,13 +1,18 @@
 module simpleRestCache
 
-go 1.12
+go 1.13
 
 require (
+	github.com/alicebob/miniredis/v2 v2.11.0
+	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/go-sql-driver/mysql v1.4.1
 	github.com/golang/protobuf v1.3.1
 	github.com/google/uuid v1.1.1
 	github.com/jinzhu/gorm v1.9.9
+	github.com/klauspost/compress v1.10.3
+	github.com/lib/pq v1.1.1 // indirect
 	github.com/mattn/go-runewidth v0.0.4 // indirect
+	github.com/mattn/go-sqlite3 v1.10.0 // indirect
 	github.com/nats-io/gnatsd v1.4.1 // indirect
 	github.com/nats-io/go-nats v1.7.2 // indirect
 	github.com/nats-io/nkeys v0.0.2 // indirect
@@ -15,5 +20,6 @@ require (
 	github.com/oklog/run v1.0.0
 	github.com/olekukonko/tablewriter v0.0.1
 	github.com/sirupsen/logrus v1.4.2
+	go.etcd.io/bbolt v1.3.5
 	google.golang.org/grpc v1.19.0
 )
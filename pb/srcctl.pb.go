@@ -7,6 +7,7 @@ import (
 	context "context"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	grpc "google.golang.org/grpc"
 	math "math"
@@ -30,6 +31,7 @@ type Cache struct {
 	RefreshDate          *timestamp.Timestamp `protobuf:"bytes,4,opt,name=refreshDate,proto3" json:"refreshDate,omitempty"`
 	RequestDate          *timestamp.Timestamp `protobuf:"bytes,5,opt,name=requestDate,proto3" json:"requestDate,omitempty"`
 	AskCount             int32                `protobuf:"varint,6,opt,name=askCount,proto3" json:"askCount,omitempty"`
+	Header               map[string]string    `protobuf:"bytes,7,rep,name=header,proto3" json:"header,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
 	XXX_unrecognized     []byte               `json:"-"`
 	XXX_sizecache        int32                `json:"-"`
@@ -102,6 +104,13 @@ func (m *Cache) GetAskCount() int32 {
 	return 0
 }
 
+func (m *Cache) GetHeader() map[string]string {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
 type AllRequest struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -172,6 +181,151 @@ func (m *AllReply) GetCache() []*Cache {
 	return nil
 }
 
+// ListRequest asks a page after a cursor, an empty cursor asks the first page.
+// Zero filters match any record.
+type ListRequest struct {
+	Cursor               string             `protobuf:"bytes,1,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Limit                int32              `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Prefix               string             `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Status               int32              `protobuf:"varint,4,opt,name=status,proto3" json:"status,omitempty"`
+	MinAge               *duration.Duration `protobuf:"bytes,5,opt,name=minAge,proto3" json:"minAge,omitempty"`
+	MaxAge               *duration.Duration `protobuf:"bytes,6,opt,name=maxAge,proto3" json:"maxAge,omitempty"`
+	MinAskCount          int32              `protobuf:"varint,7,opt,name=minAskCount,proto3" json:"minAskCount,omitempty"`
+	OmitBody             bool               `protobuf:"varint,8,opt,name=omitBody,proto3" json:"omitBody,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRequest) ProtoMessage()    {}
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1e322a80f26f6710, []int{3}
+}
+
+func (m *ListRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListRequest.Unmarshal(m, b)
+}
+func (m *ListRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListRequest.Marshal(b, m, deterministic)
+}
+func (m *ListRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListRequest.Merge(m, src)
+}
+func (m *ListRequest) XXX_Size() int {
+	return xxx_messageInfo_ListRequest.Size(m)
+}
+func (m *ListRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListRequest proto.InternalMessageInfo
+
+func (m *ListRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
+func (m *ListRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *ListRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *ListRequest) GetStatus() int32 {
+	if m != nil {
+		return m.Status
+	}
+	return 0
+}
+
+func (m *ListRequest) GetMinAge() *duration.Duration {
+	if m != nil {
+		return m.MinAge
+	}
+	return nil
+}
+
+func (m *ListRequest) GetMaxAge() *duration.Duration {
+	if m != nil {
+		return m.MaxAge
+	}
+	return nil
+}
+
+func (m *ListRequest) GetMinAskCount() int32 {
+	if m != nil {
+		return m.MinAskCount
+	}
+	return 0
+}
+
+func (m *ListRequest) GetOmitBody() bool {
+	if m != nil {
+		return m.OmitBody
+	}
+	return false
+}
+
+// ListReply is a page of records, an empty nextCursor means the last page
+type ListReply struct {
+	Cache                []*Cache `protobuf:"bytes,1,rep,name=cache,proto3" json:"cache,omitempty"`
+	NextCursor           string   `protobuf:"bytes,2,opt,name=nextCursor,proto3" json:"nextCursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListReply) Reset()         { *m = ListReply{} }
+func (m *ListReply) String() string { return proto.CompactTextString(m) }
+func (*ListReply) ProtoMessage()    {}
+func (*ListReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1e322a80f26f6710, []int{4}
+}
+
+func (m *ListReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListReply.Unmarshal(m, b)
+}
+func (m *ListReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListReply.Marshal(b, m, deterministic)
+}
+func (m *ListReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListReply.Merge(m, src)
+}
+func (m *ListReply) XXX_Size() int {
+	return xxx_messageInfo_ListReply.Size(m)
+}
+func (m *ListReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListReply proto.InternalMessageInfo
+
+func (m *ListReply) GetCache() []*Cache {
+	if m != nil {
+		return m.Cache
+	}
+	return nil
+}
+
+func (m *ListReply) GetNextCursor() string {
+	if m != nil {
+		return m.NextCursor
+	}
+	return ""
+}
+
 type TopNRequest struct {
 	N                    int32    `protobuf:"varint,1,opt,name=n,proto3" json:"n,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -183,7 +337,7 @@ func (m *TopNRequest) Reset()         { *m = TopNRequest{} }
 func (m *TopNRequest) String() string { return proto.CompactTextString(m) }
 func (*TopNRequest) ProtoMessage()    {}
 func (*TopNRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{3}
+	return fileDescriptor_1e322a80f26f6710, []int{5}
 }
 
 func (m *TopNRequest) XXX_Unmarshal(b []byte) error {
@@ -222,7 +376,7 @@ func (m *TopNReply) Reset()         { *m = TopNReply{} }
 func (m *TopNReply) String() string { return proto.CompactTextString(m) }
 func (*TopNReply) ProtoMessage()    {}
 func (*TopNReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{4}
+	return fileDescriptor_1e322a80f26f6710, []int{6}
 }
 
 func (m *TopNReply) XXX_Unmarshal(b []byte) error {
@@ -261,7 +415,7 @@ func (m *LastNRequest) Reset()         { *m = LastNRequest{} }
 func (m *LastNRequest) String() string { return proto.CompactTextString(m) }
 func (*LastNRequest) ProtoMessage()    {}
 func (*LastNRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{5}
+	return fileDescriptor_1e322a80f26f6710, []int{7}
 }
 
 func (m *LastNRequest) XXX_Unmarshal(b []byte) error {
@@ -300,7 +454,7 @@ func (m *LastNReply) Reset()         { *m = LastNReply{} }
 func (m *LastNReply) String() string { return proto.CompactTextString(m) }
 func (*LastNReply) ProtoMessage()    {}
 func (*LastNReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{6}
+	return fileDescriptor_1e322a80f26f6710, []int{8}
 }
 
 func (m *LastNReply) XXX_Unmarshal(b []byte) error {
@@ -338,7 +492,7 @@ func (m *SettingsRequest) Reset()         { *m = SettingsRequest{} }
 func (m *SettingsRequest) String() string { return proto.CompactTextString(m) }
 func (*SettingsRequest) ProtoMessage()    {}
 func (*SettingsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{7}
+	return fileDescriptor_1e322a80f26f6710, []int{9}
 }
 
 func (m *SettingsRequest) XXX_Unmarshal(b []byte) error {
@@ -370,7 +524,7 @@ func (m *SettingsReply) Reset()         { *m = SettingsReply{} }
 func (m *SettingsReply) String() string { return proto.CompactTextString(m) }
 func (*SettingsReply) ProtoMessage()    {}
 func (*SettingsReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{8}
+	return fileDescriptor_1e322a80f26f6710, []int{10}
 }
 
 func (m *SettingsReply) XXX_Unmarshal(b []byte) error {
@@ -408,7 +562,7 @@ func (m *CleanRequest) Reset()         { *m = CleanRequest{} }
 func (m *CleanRequest) String() string { return proto.CompactTextString(m) }
 func (*CleanRequest) ProtoMessage()    {}
 func (*CleanRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{9}
+	return fileDescriptor_1e322a80f26f6710, []int{11}
 }
 
 func (m *CleanRequest) XXX_Unmarshal(b []byte) error {
@@ -439,7 +593,7 @@ func (m *CleanReply) Reset()         { *m = CleanReply{} }
 func (m *CleanReply) String() string { return proto.CompactTextString(m) }
 func (*CleanReply) ProtoMessage()    {}
 func (*CleanReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{10}
+	return fileDescriptor_1e322a80f26f6710, []int{12}
 }
 
 func (m *CleanReply) XXX_Unmarshal(b []byte) error {
@@ -470,7 +624,7 @@ func (m *RefreshRequest) Reset()         { *m = RefreshRequest{} }
 func (m *RefreshRequest) String() string { return proto.CompactTextString(m) }
 func (*RefreshRequest) ProtoMessage()    {}
 func (*RefreshRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{11}
+	return fileDescriptor_1e322a80f26f6710, []int{13}
 }
 
 func (m *RefreshRequest) XXX_Unmarshal(b []byte) error {
@@ -501,7 +655,7 @@ func (m *RefreshReply) Reset()         { *m = RefreshReply{} }
 func (m *RefreshReply) String() string { return proto.CompactTextString(m) }
 func (*RefreshReply) ProtoMessage()    {}
 func (*RefreshReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_1e322a80f26f6710, []int{12}
+	return fileDescriptor_1e322a80f26f6710, []int{14}
 }
 
 func (m *RefreshReply) XXX_Unmarshal(b []byte) error {
@@ -522,10 +676,83 @@ func (m *RefreshReply) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_RefreshReply proto.InternalMessageInfo
 
+type StatsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatsRequest) Reset()         { *m = StatsRequest{} }
+func (m *StatsRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()    {}
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1e322a80f26f6710, []int{15}
+}
+
+func (m *StatsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatsRequest.Unmarshal(m, b)
+}
+func (m *StatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatsRequest.Marshal(b, m, deterministic)
+}
+func (m *StatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsRequest.Merge(m, src)
+}
+func (m *StatsRequest) XXX_Size() int {
+	return xxx_messageInfo_StatsRequest.Size(m)
+}
+func (m *StatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatsRequest proto.InternalMessageInfo
+
+type StatsReply struct {
+	Stats                []string `protobuf:"bytes,1,rep,name=stats,proto3" json:"stats,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatsReply) Reset()         { *m = StatsReply{} }
+func (m *StatsReply) String() string { return proto.CompactTextString(m) }
+func (*StatsReply) ProtoMessage()    {}
+func (*StatsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1e322a80f26f6710, []int{16}
+}
+
+func (m *StatsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatsReply.Unmarshal(m, b)
+}
+func (m *StatsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatsReply.Marshal(b, m, deterministic)
+}
+func (m *StatsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsReply.Merge(m, src)
+}
+func (m *StatsReply) XXX_Size() int {
+	return xxx_messageInfo_StatsReply.Size(m)
+}
+func (m *StatsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatsReply proto.InternalMessageInfo
+
+func (m *StatsReply) GetStats() []string {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Cache)(nil), "pb.Cache")
+	proto.RegisterMapType((map[string]string)(nil), "pb.Cache.HeaderEntry")
 	proto.RegisterType((*AllRequest)(nil), "pb.AllRequest")
 	proto.RegisterType((*AllReply)(nil), "pb.AllReply")
+	proto.RegisterType((*ListRequest)(nil), "pb.ListRequest")
+	proto.RegisterType((*ListReply)(nil), "pb.ListReply")
 	proto.RegisterType((*TopNRequest)(nil), "pb.TopNRequest")
 	proto.RegisterType((*TopNReply)(nil), "pb.TopNReply")
 	proto.RegisterType((*LastNRequest)(nil), "pb.LastNRequest")
@@ -536,40 +763,56 @@ func init() {
 	proto.RegisterType((*CleanReply)(nil), "pb.CleanReply")
 	proto.RegisterType((*RefreshRequest)(nil), "pb.RefreshRequest")
 	proto.RegisterType((*RefreshReply)(nil), "pb.RefreshReply")
+	proto.RegisterType((*StatsRequest)(nil), "pb.StatsRequest")
+	proto.RegisterType((*StatsReply)(nil), "pb.StatsReply")
 }
 
 func init() { proto.RegisterFile("srcctl.proto", fileDescriptor_1e322a80f26f6710) }
 
 var fileDescriptor_1e322a80f26f6710 = []byte{
-	// 435 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0xcb, 0x6e, 0xdb, 0x30,
-	0x10, 0x0c, 0xed, 0xc8, 0xb1, 0xd6, 0x8c, 0xe2, 0x6c, 0x2f, 0x02, 0x1b, 0x20, 0x86, 0x80, 0x02,
-	0x02, 0xd2, 0x2a, 0xa8, 0xdb, 0x63, 0x2f, 0x81, 0x7b, 0x2c, 0x7a, 0x60, 0xf2, 0x03, 0xb2, 0xc0,
-	0x38, 0x41, 0x69, 0x89, 0x15, 0xa9, 0x83, 0xff, 0xa5, 0x7f, 0xd9, 0x1f, 0x28, 0x48, 0xea, 0xe5,
-	0x02, 0x01, 0x7c, 0x9c, 0x99, 0xdd, 0xe1, 0xec, 0x48, 0x40, 0x75, 0x5d, 0x14, 0x46, 0x66, 0xaa,
-	0xae, 0x4c, 0x85, 0x13, 0xb5, 0x65, 0xb7, 0xbb, 0xaa, 0xda, 0x49, 0x71, 0xef, 0x98, 0x6d, 0xf3,
-	0x7c, 0x6f, 0x5e, 0xf7, 0x42, 0x9b, 0x7c, 0xaf, 0xfc, 0x50, 0xf2, 0x97, 0x40, 0xb0, 0xc9, 0x8b,
-	0x17, 0x81, 0x31, 0x5c, 0xd4, 0xe2, 0x77, 0x23, 0xb4, 0x89, 0xc9, 0x8a, 0xa4, 0x21, 0xef, 0x20,
-	0x32, 0x98, 0xd7, 0x42, 0xab, 0xaa, 0x2c, 0x44, 0x3c, 0x71, 0x52, 0x8f, 0xf1, 0x06, 0xc2, 0x5a,
-	0xe8, 0x47, 0x93, 0x9b, 0x46, 0xc7, 0xd3, 0x15, 0x49, 0x03, 0x3e, 0x10, 0xf8, 0x0d, 0x16, 0xb5,
-	0x78, 0xae, 0x85, 0x7e, 0xf9, 0x9e, 0x1b, 0x11, 0x9f, 0xaf, 0x48, 0xba, 0x58, 0xb3, 0xcc, 0x87,
-	0xca, 0xba, 0x50, 0xd9, 0x53, 0x17, 0x8a, 0x8f, 0xc7, 0xfd, 0xb6, 0x8b, 0xe0, 0xb6, 0x83, 0x53,
-	0xb6, 0xfb, 0x71, 0x9b, 0x3a, 0xd7, 0xbf, 0x36, 0x55, 0x53, 0x9a, 0x78, 0xe6, 0x82, 0xf5, 0x38,
-	0xa1, 0x00, 0x0f, 0x52, 0x72, 0x3f, 0x9d, 0xdc, 0xc1, 0xdc, 0x21, 0x25, 0x0f, 0x78, 0x0b, 0x41,
-	0x61, 0xeb, 0x88, 0xc9, 0x6a, 0x9a, 0x2e, 0xd6, 0x61, 0xa6, 0xb6, 0x99, 0xeb, 0x87, 0x7b, 0x3e,
-	0x79, 0x0f, 0x8b, 0xa7, 0x4a, 0xfd, 0x6c, 0x77, 0x91, 0x02, 0x29, 0x5d, 0x5f, 0x01, 0x27, 0x65,
-	0xf2, 0x11, 0x42, 0x2f, 0x9e, 0x64, 0x75, 0x03, 0xf4, 0x47, 0xae, 0xcd, 0x1b, 0x5e, 0x9f, 0x00,
-	0x5a, 0xf5, 0x24, 0xb3, 0x6b, 0xb8, 0x7a, 0x14, 0xc6, 0xbc, 0x96, 0x3b, 0x3d, 0xdc, 0x75, 0x39,
-	0x50, 0xd6, 0x84, 0xc1, 0x5c, 0xb7, 0x84, 0xf3, 0x09, 0x79, 0x8f, 0x93, 0x08, 0xe8, 0x46, 0x8a,
-	0xbc, 0xec, 0x96, 0x29, 0x40, 0x8b, 0x95, 0x3c, 0x24, 0x4b, 0x88, 0xb8, 0xff, 0x32, 0x9d, 0x1e,
-	0x01, 0xed, 0x19, 0x25, 0x0f, 0xeb, 0x3f, 0x13, 0x98, 0xf9, 0xdf, 0x0f, 0x3f, 0xc0, 0xf4, 0x41,
-	0x4a, 0x8c, 0x6c, 0xc6, 0xa1, 0x66, 0x46, 0x7b, 0x6c, 0x1d, 0xcf, 0x30, 0x85, 0x73, 0x5b, 0x16,
-	0x5e, 0x59, 0x7e, 0xd4, 0x29, 0xbb, 0x1c, 0x08, 0x3f, 0x79, 0x07, 0x81, 0xab, 0x02, 0x97, 0x56,
-	0x19, 0x77, 0xc6, 0xa2, 0x11, 0xe3, 0x87, 0xbf, 0xc2, 0xbc, 0xbb, 0x1a, 0xdf, 0x59, 0xf5, 0xbf,
-	0x5a, 0xd8, 0xf5, 0x31, 0xd9, 0x3f, 0xe1, 0xce, 0xf5, 0x4f, 0x8c, 0x9b, 0xf0, 0x4f, 0x8c, 0xba,
-	0x38, 0xc3, 0xcf, 0x70, 0xd1, 0xde, 0x8e, 0x68, 0xc5, 0xe3, 0x6a, 0xd8, 0xf2, 0x88, 0x73, 0x2b,
-	0xdb, 0x99, 0xfb, 0x5d, 0xbf, 0xfc, 0x0b, 0x00, 0x00, 0xff, 0xff, 0x03, 0x5f, 0x53, 0x16, 0xa3,
-	0x03, 0x00, 0x00,
+	// 668 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x5d, 0x6e, 0xd3, 0x4c,
+	0x14, 0xfd, 0x9c, 0xd4, 0x4e, 0x7c, 0xed, 0xba, 0xe9, 0x7c, 0x05, 0x99, 0xa1, 0x6a, 0x23, 0x4b,
+	0x48, 0x91, 0x4a, 0x5d, 0xb5, 0xf0, 0x00, 0x88, 0x97, 0x92, 0x22, 0xf1, 0x50, 0xf1, 0x30, 0xed,
+	0x06, 0x9c, 0x74, 0x9a, 0x5a, 0x75, 0x6c, 0x33, 0x33, 0x46, 0xcd, 0x2a, 0x58, 0x21, 0xab, 0x60,
+	0x03, 0x68, 0x7e, 0xec, 0x4c, 0x8b, 0x10, 0x79, 0xcb, 0x39, 0xf7, 0xdc, 0x9f, 0x9c, 0x7b, 0xc7,
+	0x10, 0x72, 0x36, 0x9f, 0x8b, 0x22, 0xad, 0x59, 0x25, 0x2a, 0xd4, 0xab, 0x67, 0xf8, 0x70, 0x51,
+	0x55, 0x8b, 0x82, 0x9e, 0x28, 0x66, 0xd6, 0xdc, 0x9e, 0x88, 0x7c, 0x49, 0xb9, 0xc8, 0x96, 0xb5,
+	0x16, 0xe1, 0x83, 0xa7, 0x82, 0x9b, 0x86, 0x65, 0x22, 0xaf, 0x4a, 0x1d, 0x4f, 0x7e, 0xf6, 0xc0,
+	0x9d, 0x66, 0xf3, 0x3b, 0x8a, 0x62, 0x18, 0x30, 0xfa, 0xad, 0xa1, 0x5c, 0xc4, 0xce, 0xd8, 0x99,
+	0xf8, 0xa4, 0x85, 0x08, 0xc3, 0x90, 0x51, 0x5e, 0x57, 0xe5, 0x9c, 0xc6, 0x3d, 0x15, 0xea, 0x30,
+	0xda, 0x07, 0x9f, 0x51, 0x7e, 0x25, 0x32, 0xd1, 0xf0, 0xb8, 0x3f, 0x76, 0x26, 0x2e, 0x59, 0x13,
+	0xe8, 0x23, 0x04, 0x8c, 0xde, 0x32, 0xca, 0xef, 0x2e, 0x32, 0x41, 0xe3, 0xad, 0xb1, 0x33, 0x09,
+	0xce, 0x70, 0xaa, 0x67, 0x4a, 0xdb, 0x99, 0xd2, 0xeb, 0x76, 0x68, 0x62, 0xcb, 0x75, 0xb6, 0x1a,
+	0x41, 0x65, 0xbb, 0x9b, 0x64, 0x77, 0x72, 0x39, 0x75, 0xc6, 0xef, 0xa7, 0x55, 0x53, 0x8a, 0xd8,
+	0x53, 0x83, 0x75, 0x18, 0x1d, 0x83, 0x77, 0x47, 0xb3, 0x1b, 0xca, 0xe2, 0xc1, 0xb8, 0x3f, 0x09,
+	0xce, 0x9e, 0xa5, 0xf5, 0x2c, 0x55, 0x36, 0xa4, 0x5f, 0x14, 0xff, 0xb9, 0x14, 0x6c, 0x45, 0x8c,
+	0x08, 0xbf, 0x87, 0xc0, 0xa2, 0xd1, 0x08, 0xfa, 0xf7, 0x74, 0x65, 0x5c, 0x92, 0x3f, 0xd1, 0x1e,
+	0xb8, 0xdf, 0xb3, 0xa2, 0x69, 0xed, 0xd1, 0xe0, 0x43, 0xef, 0x9d, 0x93, 0x84, 0x00, 0xe7, 0x45,
+	0x41, 0xf4, 0x5c, 0xc9, 0x11, 0x0c, 0x15, 0xaa, 0x8b, 0x15, 0x3a, 0x04, 0x77, 0x2e, 0x3b, 0xc6,
+	0x8e, 0x1a, 0xc1, 0xef, 0x46, 0x20, 0x9a, 0x4f, 0x7e, 0xf4, 0x20, 0xb8, 0xcc, 0xb9, 0x30, 0xc9,
+	0xe8, 0x39, 0x78, 0xf3, 0x86, 0xf1, 0x8a, 0x99, 0xce, 0x06, 0xc9, 0xe6, 0x45, 0xbe, 0xcc, 0x85,
+	0x6a, 0xee, 0x12, 0x0d, 0xa4, 0xba, 0x66, 0xf4, 0x36, 0x7f, 0x50, 0x5b, 0xf1, 0x89, 0x41, 0x92,
+	0xe7, 0x7a, 0x5b, 0x5b, 0x4a, 0x6e, 0x10, 0x3a, 0x05, 0x6f, 0x99, 0x97, 0xe7, 0x8b, 0xd6, 0xe7,
+	0x17, 0x7f, 0xf8, 0x7c, 0x61, 0x2e, 0x87, 0x18, 0xa1, 0x4a, 0xc9, 0x1e, 0x64, 0x8a, 0xf7, 0xef,
+	0x14, 0x25, 0x44, 0x63, 0x08, 0x64, 0x72, 0xbb, 0x97, 0x81, 0x1a, 0xc1, 0xa6, 0xe4, 0xda, 0xaa,
+	0x65, 0x2e, 0x3e, 0x55, 0x37, 0xab, 0x78, 0x38, 0x76, 0x26, 0x43, 0xd2, 0xe1, 0xe4, 0x12, 0x7c,
+	0x6d, 0xc8, 0x26, 0xfe, 0xa1, 0x03, 0x80, 0x92, 0x3e, 0x88, 0xa9, 0xf6, 0x4c, 0x6f, 0xc6, 0x62,
+	0x92, 0x97, 0x10, 0x5c, 0x57, 0xf5, 0xd7, 0xd6, 0xde, 0x10, 0x9c, 0x52, 0x39, 0xeb, 0x12, 0xa7,
+	0x4c, 0x5e, 0x83, 0xaf, 0x83, 0x1b, 0xad, 0x6a, 0x1f, 0xc2, 0xcb, 0x8c, 0x8b, 0xbf, 0xd4, 0x3a,
+	0x06, 0x30, 0xd1, 0x8d, 0x8a, 0xed, 0xc2, 0xce, 0x15, 0x15, 0x22, 0x2f, 0x17, 0x7c, 0x7d, 0x37,
+	0xdb, 0x6b, 0x4a, 0x16, 0xc1, 0x30, 0xe4, 0x86, 0x50, 0x75, 0x7c, 0xd2, 0xe1, 0x24, 0x82, 0x70,
+	0x5a, 0xd0, 0xac, 0x6c, 0x93, 0x43, 0x00, 0x83, 0xeb, 0x62, 0x95, 0x8c, 0x20, 0x22, 0xfa, 0x8d,
+	0xb5, 0xf1, 0x08, 0xc2, 0x8e, 0x91, 0x8a, 0x08, 0x42, 0xf9, 0x7c, 0xbb, 0xe6, 0x09, 0x80, 0xc1,
+	0xb2, 0xf3, 0x1e, 0xb8, 0xf2, 0x62, 0xda, 0xb6, 0x1a, 0x9c, 0xfd, 0xea, 0x81, 0xa7, 0x3f, 0x4e,
+	0xe8, 0x15, 0xf4, 0xcf, 0x8b, 0x02, 0x45, 0xf2, 0x7f, 0xad, 0x4f, 0x1f, 0x87, 0x1d, 0x96, 0x3d,
+	0xfe, 0x43, 0x13, 0xd8, 0x92, 0x06, 0xa3, 0x1d, 0xc9, 0x5b, 0x7b, 0xc0, 0xdb, 0x6b, 0x42, 0x2b,
+	0x8f, 0xc0, 0x55, 0xf6, 0xa1, 0x91, 0x8c, 0xd8, 0x3e, 0xe3, 0xc8, 0x62, 0xb4, 0xf8, 0x2d, 0x0c,
+	0x5b, 0xa7, 0xd0, 0xff, 0x32, 0xfa, 0xc4, 0x4a, 0xbc, 0xfb, 0x98, 0xec, 0x5a, 0x28, 0x8b, 0x74,
+	0x0b, 0xdb, 0x3d, 0x1c, 0x59, 0x8c, 0x16, 0x9f, 0xc2, 0xc0, 0xf8, 0x85, 0x90, 0x0c, 0x3e, 0xb6,
+	0x13, 0x8f, 0x1e, 0x71, 0x5d, 0x7d, 0x65, 0xa1, 0xae, 0x6f, 0xbb, 0x8b, 0x23, 0x8b, 0xe9, 0x9c,
+	0x91, 0x57, 0xae, 0x9d, 0xb1, 0x3e, 0x00, 0x78, 0x7b, 0x4d, 0x28, 0xe5, 0xcc, 0x53, 0x0f, 0xed,
+	0xcd, 0xef, 0x01, 0x00, 0xc0, 0xc2, 0xe2, 0xd2, 0x18, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -591,6 +834,9 @@ type SrcctlClient interface {
 	Settings(ctx context.Context, in *SettingsRequest, opts ...grpc.CallOption) (*SettingsReply, error)
 	Clean(ctx context.Context, in *CleanRequest, opts ...grpc.CallOption) (*CleanReply, error)
 	Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshReply, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	// A page of requests in cache selected by filters
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReply, error)
 }
 
 type srcctlClient struct {
@@ -655,6 +901,24 @@ func (c *srcctlClient) Refresh(ctx context.Context, in *RefreshRequest, opts ...
 	return out, nil
 }
 
+func (c *srcctlClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error) {
+	out := new(StatsReply)
+	err := c.cc.Invoke(ctx, "/pb.srcctl/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *srcctlClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReply, error) {
+	out := new(ListReply)
+	err := c.cc.Invoke(ctx, "/pb.srcctl/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SrcctlServer is the server API for Srcctl service.
 type SrcctlServer interface {
 	// Top N requests in cache
@@ -664,6 +928,9 @@ type SrcctlServer interface {
 	Settings(context.Context, *SettingsRequest) (*SettingsReply, error)
 	Clean(context.Context, *CleanRequest) (*CleanReply, error)
 	Refresh(context.Context, *RefreshRequest) (*RefreshReply, error)
+	Stats(context.Context, *StatsRequest) (*StatsReply, error)
+	// A page of requests in cache selected by filters
+	List(context.Context, *ListRequest) (*ListReply, error)
 }
 
 func RegisterSrcctlServer(s *grpc.Server, srv SrcctlServer) {
@@ -778,6 +1045,42 @@ func _Srcctl_Refresh_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Srcctl_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SrcctlServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.srcctl/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SrcctlServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Srcctl_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SrcctlServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.srcctl/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SrcctlServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Srcctl_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.srcctl",
 	HandlerType: (*SrcctlServer)(nil),
@@ -806,6 +1109,14 @@ var _Srcctl_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Refresh",
 			Handler:    _Srcctl_Refresh_Handler,
 		},
+		{
+			MethodName: "Stats",
+			Handler:    _Srcctl_Stats_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Srcctl_List_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "srcctl.proto",
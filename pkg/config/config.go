@@ -2,34 +2,119 @@ package config
 
 import (
 	"flag"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"simpleRestCache/pkg/parser"
 )
 
 // Config contains all configuration of App
 type Config struct {
-	APIAddr       string
-	DSN           string
-	HTTPAddr      string
-	CtlAddr       string
-	ExpiredPeriod time.Duration
-	SLA           time.Duration
-	Debug         bool
+	APIAddr                     string
+	Parser                      string
+	DSN                         string
+	Dialect                     string
+	HTTPAddr                    string
+	CtlAddr                     string
+	ExpiredPeriod               time.Duration
+	NegativeExpiredPeriod       time.Duration
+	CacheableStatuses           []int
+	StoredHeaders               []string
+	KeyDropParams               []string
+	KeyHeaders                  []string
+	HonorCacheHeaders           bool
+	SLA                         time.Duration
+	StaleWhileRevalidate        time.Duration
+	Rules                       []Rule
+	Routes                      []Route
+	UpstreamDialTimeout         time.Duration
+	UpstreamTLSTimeout          time.Duration
+	UpstreamResponseTimeout     time.Duration
+	UpstreamMaxIdleConnsPerHost int
+	UpstreamRetries             int
+	UpstreamBackoff             time.Duration
+	UpstreamMaxBackoff          time.Duration
+	BreakerErrorRate            float64
+	BreakerLatency              time.Duration
+	BreakerWindow               int
+	BreakerMinRequests          int
+	BreakerOpenTimeout          time.Duration
+	Storage                     string
+	Tiered                      bool
+	InmemMaxEntries             int
+	InmemMaxBytes               int64
+	InmemEviction               string
+	InmemShards                 int
+	InmemSnapshot               string
+	InmemSnapshotInterval       time.Duration
+	RedisURL                    string
+	RedisKeyTTL                 time.Duration
+	BoltPath                    string
+	StatFlushInterval           time.Duration
+	PurgeRetention              time.Duration
+	PurgeInterval               time.Duration
+	PurgeBatch                  int
+	Compression                 string
+	Debug                       bool
 }
 
 // GetConfig returns a fulfilled Config
 func GetConfig() *Config {
 	fs := flag.NewFlagSet("simpleRESTcache", flag.ExitOnError)
 	var (
-		apiAddr       = fs.String("api-URL", "https://places.aviasales.ru/v2/places.json", "URL of an endpoint API")
-		dsn           = fs.String("dsn", "root:root@tcp(mysql:3306)/tasks?charset=utf8&parseTime=True&loc=Local", "Database Source Name")
-		httpAddr      = fs.String("http-addr", ":8080", "HTTP listen address")
-		ctlAddr       = fs.String("control-addr", ":8081", "Control listen address")
-		expiredPeriod = fs.Duration("expiredPeriod", 24*time.Hour, "Expired cache duration. Valid time units are \"m\", \"h\"")
-		sla           = fs.Duration("sla", 3*time.Second, "SLA time is a period for which a response to a client must be provided. Valid time units are \"ms\", \"s\", \"m\", \"h\"")
-		debug         = fs.Bool("debug", false, "Set debug mode")
+		apiAddr        = fs.String("api-URL", "https://places.aviasales.ru/v2/places.json", "URL of an endpoint API")
+		parserName     = fs.String("parser", "aviasalesru/placesjsonv2", "Name of a parser which transforms endpoint API responses")
+		dsn            = fs.String("dsn", "root:root@tcp(mysql:3306)/tasks?charset=utf8&parseTime=True&loc=Local", "Database Source Name")
+		dialect        = fs.String("dialect", "", "SQL dialect of gorm storage: mysql, postgres or sqlite3. By default it is chosen by a scheme of dsn: postgres:// or postgresql:// is postgres, sqlite3://<file> is sqlite3, others are mysql")
+		httpAddr       = fs.String("http-addr", ":8080", "HTTP listen address")
+		ctlAddr        = fs.String("control-addr", ":8081", "Control listen address")
+		expiredPeriod  = fs.Duration("expiredPeriod", 24*time.Hour, "Expired cache duration. Valid time units are \"m\", \"h\"")
+		sla            = fs.Duration("sla", 3*time.Second, "SLA time is a period for which a response to a client must be provided. Valid time units are \"ms\", \"s\", \"m\", \"h\"")
+		negativePeriod = fs.Duration("negativeExpiredPeriod", 1*time.Minute, "Expired cache duration for 4xx responses. Valid time units are \"s\", \"m\", \"h\"")
+		cacheable      = fs.String("cacheable-statuses", "200,203,204,300,301,404,410", "Comma separated list of status codes of endpoint API responses which are stored in cache")
+		storedHeaders  = fs.String("stored-headers", "Content-Type,Content-Language", "Comma separated list of headers of endpoint API responses which are stored in cache and returned to clients")
+		keyDropParams  = fs.String("key-drop-params", "utm_*,fbclid,gclid", "Comma separated list of query parameters which are not a part of a cache key, e.g. tracking parameters. A parameter can be a pattern like utm_*")
+		keyHeaders     = fs.String("key-headers", "", "Comma separated list of request headers which are a part of a cache key and are sent to endpoint APIs, e.g. Accept-Language")
+		honorHeaders   = fs.Bool("honor-cache-headers", false, "Use Cache-Control and Expires headers of endpoint API responses for cache expiration and revalidate cache with ETag and Last-Modified")
+		swr            = fs.Duration("staleWhileRevalidate", 0, "Period after cache expiration during which an expired cache is returned immediately and refreshed in background. 0 disables it. Valid time units are \"m\", \"h\"")
+		routesFile     = fs.String("routes", "", "JSON file with routes which map local path prefixes to endpoint APIs. By default the path of api-URL is mapped to api-URL")
+		rulesFile      = fs.String("rules", "", "JSON file with rules which override expiredPeriod, sla, staleWhileRevalidate and parser for matched requests")
+		dialTimeout    = fs.Duration("upstream-dial-timeout", 5*time.Second, "Timeout for establishing a connection to an endpoint API")
+		tlsTimeout     = fs.Duration("upstream-tls-timeout", 5*time.Second, "Timeout for a TLS handshake with an endpoint API")
+		respTimeout    = fs.Duration("upstream-response-timeout", 10*time.Second, "Timeout for waiting response headers from an endpoint API")
+		maxIdleConns   = fs.Int("upstream-max-idle-conns", 16, "Maximum idle (keep-alive) connections to an endpoint API")
+		retries        = fs.Int("upstream-retries", 2, "Maximum number of retries of a request to an endpoint API on connection errors and 502/503/504 responses")
+		backoff        = fs.Duration("upstream-backoff", 50*time.Millisecond, "Initial delay between retries of a request to an endpoint API. The delay grows exponentially")
+		maxBackoff     = fs.Duration("upstream-max-backoff", 1*time.Second, "Maximum delay between retries of a request to an endpoint API")
+		errorRate      = fs.Float64("breaker-error-rate", 0.5, "Rate of failed requests to an endpoint API in a window which opens circuit breaker. 0 disables circuit breaker")
+		breakerLatency = fs.Duration("breaker-latency", 0, "Requests to an endpoint API slower then this are counted as failed by circuit breaker. 0 means SLA")
+		breakerWindow  = fs.Int("breaker-window", 20, "Number of last requests to an endpoint API which circuit breaker takes into account")
+		minRequests    = fs.Int("breaker-min-requests", 10, "Minimum number of requests in a window before circuit breaker can open")
+		openTimeout    = fs.Duration("breaker-open-timeout", 30*time.Second, "Period after which open circuit breaker lets a probe request to an endpoint API through")
+		storage        = fs.String("storage", "gorm", "Storage of cache records: gorm, inmem, redis or bolt")
+		tiered         = fs.Bool("tiered", false, "Put bounded inmem storage as L1 in front of storage. Limits and eviction policy of L1 are set by inmem-* arguments")
+		maxEntries     = fs.Int("inmem-max-entries", 0, "Maximum number of records in inmem storage. 0 means unlimited")
+		maxBytes       = fs.Int64("inmem-max-bytes", 0, "Maximum size of records in inmem storage in bytes. 0 means unlimited")
+		eviction       = fs.String("inmem-eviction", "lru", "Eviction policy of inmem storage: lru (least recently used) or lfu (least frequently used)")
+		shards         = fs.Int("inmem-shards", 1, "Number of shards of inmem storage. Records are spread between shards by a hash of a request to reduce lock contention. Limits are divided between shards, so a record larger then inmem-max-bytes / inmem-shards is not stored. It is clamped to inmem-max-entries")
+		snapshot       = fs.String("inmem-snapshot", "", "File of a snapshot of inmem storage. A snapshot is written on shutdown and loaded on start. Empty disables snapshots")
+		snapInterval   = fs.Duration("inmem-snapshot-interval", 0, "Interval of writing a snapshot of inmem storage in addition to shutdown. 0 means only on shutdown")
+		redisURL       = fs.String("redis-url", "redis://redis:6379/0", "URL of Redis for redis storage")
+		redisKeyTTL    = fs.Duration("redis-key-ttl", 72*time.Hour, "Time to live of cache records in redis storage. It should be more then expiredPeriod to serve expired cache when an endpoint API is unavailable. 0 means records never expire")
+		boltPath       = fs.String("bolt-path", "simplerestcache.db", "File of bolt storage")
+		statFlush      = fs.Duration("stat-flush-interval", 1*time.Second, "Interval of writing collected statistic of cache hits (AskCount and RequestDate) to a storage")
+		purgeRetention = fs.Duration("purge-retention", 0, "Records which were neither requested nor refreshed during this period are deleted by a janitor. 0 disables the janitor. Valid time units are \"m\", \"h\"")
+		purgeInterval  = fs.Duration("purge-interval", 1*time.Hour, "Interval between runs of the janitor")
+		purgeBatch     = fs.Int("purge-batch", 500, "Maximum number of records deleted by one query of the janitor")
+		compression    = fs.String("compression", "none", "Compression of stored responses: none, gzip or zstd. A client which accepts gzip gets a gzip compressed responce without recompression")
+		debug          = fs.Bool("debug", false, "Set debug mode")
 	)
 
 	fs.Parse(os.Args[1:])
@@ -40,20 +125,299 @@ func GetConfig() *Config {
 		os.Exit(1)
 	}
 
+	if *negativePeriod <= 0 {
+		log.Error("Expired cache duration for 4xx responses should be positive")
+		os.Exit(1)
+	}
+
+	statuses := []int{}
+	for _, v := range strings.Split(*cacheable, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 100 || code > 599 {
+			log.WithFields(log.Fields{
+				"status": v,
+			}).Error("Wrong status code in a list of cacheable status codes")
+			os.Exit(1)
+		}
+		statuses = append(statuses, code)
+	}
+
+	headers := []string{}
+	for _, v := range strings.Split(*storedHeaders, ",") {
+		v = http.CanonicalHeaderKey(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		switch v {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Connection", "Vary":
+			// they are set by the HTTP server for every responce
+			log.WithFields(log.Fields{
+				"header": v,
+			}).Error("Header cannot be stored in cache")
+			os.Exit(1)
+		}
+		headers = append(headers, v)
+	}
+
+	dropParams := []string{}
+	for _, v := range strings.Split(*keyDropParams, ",") {
+		// names of query parameters are lower cased in a cache key
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if _, err := path.Match(v, ""); err != nil {
+			log.WithFields(log.Fields{
+				"param": v,
+				"err":   err,
+			}).Error("Malformed pattern of a dropped query parameter")
+			os.Exit(1)
+		}
+		dropParams = append(dropParams, v)
+	}
+
+	varyHeaders := []string{}
+	for _, v := range strings.Split(*keyHeaders, ",") {
+		v = http.CanonicalHeaderKey(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		varyHeaders = append(varyHeaders, v)
+	}
+
 	if *sla < 1*time.Millisecond {
 		log.Error("SLA time should be more then 1ms")
 		os.Exit(1)
 	}
 
+	if *swr < 0 {
+		log.Error("Stale-while-revalidate period should not be negative")
+		os.Exit(1)
+	}
+
+	if _, err := parser.Get(*parserName); err != nil {
+		log.WithFields(log.Fields{
+			"parsers": parser.Names(),
+			"err":     err,
+		}).Error("Unknown parser")
+		os.Exit(1)
+	}
+
+	routes := []Route{}
+	if *routesFile != "" {
+		var err error
+		routes, err = LoadRoutes(*routesFile)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"file": *routesFile,
+				"err":  err,
+			}).Error("Cannot load routes")
+			os.Exit(1)
+		}
+	} else {
+		r, err := DefaultRoute(*apiAddr)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot create a route")
+			os.Exit(1)
+		}
+		routes = append(routes, r)
+	}
+
+	rules := []Rule{}
+	if *rulesFile != "" {
+		var err error
+		rules, err = LoadRules(*rulesFile)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"file": *rulesFile,
+				"err":  err,
+			}).Error("Cannot load rules")
+			os.Exit(1)
+		}
+	}
+
+	if *retries < 0 {
+		log.Error("Number of retries should not be negative")
+		os.Exit(1)
+	}
+
+	if *backoff <= 0 || *maxBackoff < *backoff {
+		log.Error("Backoff should be positive and not more then maximum backoff")
+		os.Exit(1)
+	}
+
+	if *errorRate < 0 || *errorRate > 1 {
+		log.Error("Error rate of circuit breaker should be between 0 and 1")
+		os.Exit(1)
+	}
+
+	if *breakerWindow < 1 || *minRequests < 1 || *minRequests > *breakerWindow {
+		log.Error("Window of circuit breaker should be positive and not less then minimum number of requests")
+		os.Exit(1)
+	}
+
+	if *storage != "gorm" && *storage != "inmem" && *storage != "redis" && *storage != "bolt" {
+		log.WithFields(log.Fields{
+			"storage": *storage,
+		}).Error("Unknown storage")
+		os.Exit(1)
+	}
+
+	if *dialect != "" && *dialect != "mysql" && *dialect != "postgres" && *dialect != "sqlite3" {
+		log.WithFields(log.Fields{
+			"dialect": *dialect,
+		}).Error("Unknown SQL dialect")
+		os.Exit(1)
+	}
+
+	if *maxEntries < 0 || *maxBytes < 0 {
+		log.Error("Limits of inmem storage should not be negative")
+		os.Exit(1)
+	}
+
+	if *tiered && *storage == "inmem" {
+		log.Error("Inmem storage cannot be L2 of tiered storage")
+		os.Exit(1)
+	}
+
+	if *tiered && *maxEntries == 0 && *maxBytes == 0 {
+		log.Error("L1 of tiered storage should be bounded by a number of records or their size")
+		os.Exit(1)
+	}
+
+	if *eviction != "lru" && *eviction != "lfu" {
+		log.WithFields(log.Fields{
+			"eviction": *eviction,
+		}).Error("Unknown eviction policy of inmem storage")
+		os.Exit(1)
+	}
+
+	if *shards < 1 {
+		log.Error("Number of shards of inmem storage should be positive")
+		os.Exit(1)
+	}
+	if *maxEntries > 0 && *shards > *maxEntries {
+		// every shard holds at least one record
+		log.WithFields(log.Fields{
+			"shards":     *shards,
+			"maxEntries": *maxEntries,
+		}).Warn("Number of shards of inmem storage is clamped to the maximum number of records")
+		*shards = *maxEntries
+	}
+
+	if *snapInterval < 0 {
+		log.Error("Interval of snapshots of inmem storage should not be negative")
+		os.Exit(1)
+	}
+
+	if *snapInterval > 0 && *snapshot == "" {
+		log.Error("Interval of snapshots of inmem storage needs a file of a snapshot")
+		os.Exit(1)
+	}
+
+	if u, err := url.Parse(*redisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+		log.WithFields(log.Fields{
+			"url": *redisURL,
+		}).Error("Wrong URL of Redis")
+		os.Exit(1)
+	}
+
+	if *redisKeyTTL < 0 {
+		log.Error("Time to live of records in redis storage should not be negative")
+		os.Exit(1)
+	}
+
+	if *statFlush <= 0 {
+		log.Error("Interval of writing statistic should be positive")
+		os.Exit(1)
+	}
+
+	if *purgeRetention < 0 {
+		log.Error("Retention period of records should not be negative")
+		os.Exit(1)
+	}
+
+	if *purgeInterval <= 0 {
+		log.Error("Interval of the janitor should be positive")
+		os.Exit(1)
+	}
+
+	if *purgeBatch < 1 {
+		log.Error("Batch size of the janitor should be positive")
+		os.Exit(1)
+	}
+
+	if *compression != "none" && *compression != "gzip" && *compression != "zstd" {
+		log.WithFields(log.Fields{
+			"compression": *compression,
+		}).Error("Unknown compression of stored responses")
+		os.Exit(1)
+	}
+
 	cfg := Config{
-		APIAddr:       *apiAddr,
-		DSN:           *dsn,
-		HTTPAddr:      *httpAddr,
-		CtlAddr:       *ctlAddr,
-		ExpiredPeriod: *expiredPeriod,
-		SLA:           *sla,
-		Debug:         *debug,
+		APIAddr:                     *apiAddr,
+		Parser:                      *parserName,
+		DSN:                         *dsn,
+		Dialect:                     *dialect,
+		HTTPAddr:                    *httpAddr,
+		CtlAddr:                     *ctlAddr,
+		ExpiredPeriod:               *expiredPeriod,
+		NegativeExpiredPeriod:       *negativePeriod,
+		CacheableStatuses:           statuses,
+		StoredHeaders:               headers,
+		KeyDropParams:               dropParams,
+		KeyHeaders:                  varyHeaders,
+		HonorCacheHeaders:           *honorHeaders,
+		SLA:                         *sla,
+		StaleWhileRevalidate:        *swr,
+		Rules:                       rules,
+		Routes:                      routes,
+		UpstreamDialTimeout:         *dialTimeout,
+		UpstreamTLSTimeout:          *tlsTimeout,
+		UpstreamResponseTimeout:     *respTimeout,
+		UpstreamMaxIdleConnsPerHost: *maxIdleConns,
+		UpstreamRetries:             *retries,
+		UpstreamBackoff:             *backoff,
+		UpstreamMaxBackoff:          *maxBackoff,
+		BreakerErrorRate:            *errorRate,
+		BreakerLatency:              *breakerLatency,
+		BreakerWindow:               *breakerWindow,
+		BreakerMinRequests:          *minRequests,
+		BreakerOpenTimeout:          *openTimeout,
+		Storage:                     *storage,
+		Tiered:                      *tiered,
+		InmemMaxEntries:             *maxEntries,
+		InmemMaxBytes:               *maxBytes,
+		InmemEviction:               *eviction,
+		InmemShards:                 *shards,
+		InmemSnapshot:               *snapshot,
+		InmemSnapshotInterval:       *snapInterval,
+		RedisURL:                    *redisURL,
+		RedisKeyTTL:                 *redisKeyTTL,
+		BoltPath:                    *boltPath,
+		StatFlushInterval:           *statFlush,
+		PurgeRetention:              *purgeRetention,
+		PurgeInterval:               *purgeInterval,
+		PurgeBatch:                  *purgeBatch,
+		Compression:                 *compression,
+		Debug:                       *debug,
 	}
 
 	return &cfg
 }
+
+// Cacheable reports whether a responce with a status code should be stored in cache
+func (c *Config) Cacheable(status int) bool {
+	for _, s := range c.CacheableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
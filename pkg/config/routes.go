@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"simpleRestCache/pkg/parser"
+)
+
+// Route maps a local path prefix to an endpoint.
+// A prefix ending with "/" matches a subtree, otherwise it matches one path.
+// Zero durations and an empty Parser mean global settings.
+type Route struct {
+	Prefix               string
+	Upstream             string
+	Parser               string
+	TTL                  time.Duration
+	SLA                  time.Duration
+	StaleWhileRevalidate time.Duration
+}
+
+// jsonRoute is a Route in a routes file
+type jsonRoute struct {
+	Prefix               string `json:"prefix"`
+	Upstream             string `json:"upstream"`
+	Parser               string `json:"parser"`
+	TTL                  string `json:"ttl"`
+	SLA                  string `json:"sla"`
+	StaleWhileRevalidate string `json:"staleWhileRevalidate"`
+}
+
+// LoadRoutes reads routes from a JSON file
+func LoadRoutes(file string) ([]Route, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	jrs := []jsonRoute{}
+	if err := json.Unmarshal(data, &jrs); err != nil {
+		return nil, err
+	}
+	if len(jrs) == 0 {
+		return nil, fmt.Errorf("there are no routes")
+	}
+
+	routes := []Route{}
+	prefixes := map[string]bool{}
+	for i, jr := range jrs {
+		r := Route{
+			Prefix:   jr.Prefix,
+			Upstream: jr.Upstream,
+			Parser:   jr.Parser,
+		}
+		if !strings.HasPrefix(r.Prefix, "/") {
+			return nil, fmt.Errorf("route %v: prefix %q should start with \"/\"", i, r.Prefix)
+		}
+		if prefixes[r.Prefix] {
+			return nil, fmt.Errorf("route %v: duplicate prefix %q", i, r.Prefix)
+		}
+		prefixes[r.Prefix] = true
+		if u, err := url.Parse(r.Upstream); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("route %v: wrong upstream URL %q", i, r.Upstream)
+		}
+		if r.Parser != "" {
+			if _, err := parser.Get(r.Parser); err != nil {
+				return nil, fmt.Errorf("route %v: %v", i, err)
+			}
+		}
+		if err := parseDurations(
+			durationField{"ttl", jr.TTL, &r.TTL},
+			durationField{"sla", jr.SLA, &r.SLA},
+			durationField{"staleWhileRevalidate", jr.StaleWhileRevalidate, &r.StaleWhileRevalidate},
+		); err != nil {
+			return nil, fmt.Errorf("route %v: %v", i, err)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// DefaultRoute returns a route which maps a path of an endpoint URL to the endpoint
+func DefaultRoute(apiAddr string) (Route, error) {
+	u, err := url.Parse(apiAddr)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Route{}, fmt.Errorf("wrong URL of an endpoint API %q", apiAddr)
+	}
+	prefix := u.Path
+	if prefix == "" {
+		prefix = "/"
+	}
+	return Route{
+		Prefix:   prefix,
+		Upstream: apiAddr,
+	}, nil
+}
+
+// URL returns an URL of the endpoint for a local path with a query
+func (r Route) URL(pathQuery string) string {
+	rest := strings.TrimPrefix(pathQuery, r.Prefix)
+	if strings.HasSuffix(r.Prefix, "/") && !strings.HasSuffix(r.Upstream, "/") {
+		return r.Upstream + "/" + rest
+	}
+	return r.Upstream + rest
+}
+
+// Match reports whether a local path belongs to the route
+func (r Route) Match(path string) bool {
+	if strings.HasSuffix(r.Prefix, "/") {
+		return strings.HasPrefix(path, r.Prefix)
+	}
+	return path == r.Prefix
+}
+
+// String returns a short description of the route
+func (r Route) String() string {
+	d := func(v time.Duration) string {
+		if v == 0 {
+			return "-"
+		}
+		return v.String()
+	}
+	p := r.Parser
+	if p == "" {
+		p = "-"
+	}
+	return fmt.Sprintf("prefix=%v upstream=%v parser=%v ttl=%v sla=%v staleWhileRevalidate=%v",
+		r.Prefix, r.Upstream, p, d(r.TTL), d(r.SLA), d(r.StaleWhileRevalidate))
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"simpleRestCache/pkg/parser"
+)
+
+// Rule overrides cache settings for requests matched by a path and query parameters.
+// Path and values of Query are patterns in path.Match syntax.
+// An empty Path matches any path. Zero durations and an empty Parser mean global settings.
+type Rule struct {
+	Path                 string
+	Query                map[string]string
+	TTL                  time.Duration
+	SLA                  time.Duration
+	StaleWhileRevalidate time.Duration
+	Parser               string
+}
+
+// jsonRule is a Rule in a rules file
+type jsonRule struct {
+	Path                 string            `json:"path"`
+	Query                map[string]string `json:"query"`
+	TTL                  string            `json:"ttl"`
+	SLA                  string            `json:"sla"`
+	StaleWhileRevalidate string            `json:"staleWhileRevalidate"`
+	Parser               string            `json:"parser"`
+}
+
+// LoadRules reads rules from a JSON file
+func LoadRules(file string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	jrs := []jsonRule{}
+	if err := json.Unmarshal(data, &jrs); err != nil {
+		return nil, err
+	}
+
+	rules := []Rule{}
+	for i, jr := range jrs {
+		r := Rule{
+			Path:   jr.Path,
+			Query:  map[string]string{},
+			Parser: jr.Parser,
+		}
+		// names of query parameters are lower cased in cache keys
+		for k, v := range jr.Query {
+			lk := strings.ToLower(k)
+			if _, ok := r.Query[lk]; ok {
+				return nil, fmt.Errorf("rule %v: query parameter %q is set twice", i, lk)
+			}
+			r.Query[lk] = v
+		}
+		if r.Parser != "" {
+			if _, err := parser.Get(r.Parser); err != nil {
+				return nil, fmt.Errorf("rule %v: %v", i, err)
+			}
+		}
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return nil, fmt.Errorf("rule %v: wrong path pattern %q: %v", i, r.Path, err)
+		}
+		for k, v := range r.Query {
+			if _, err := path.Match(v, ""); err != nil {
+				return nil, fmt.Errorf("rule %v: wrong pattern %q of query parameter %q: %v", i, v, k, err)
+			}
+		}
+		if err := parseDurations(
+			durationField{"ttl", jr.TTL, &r.TTL},
+			durationField{"sla", jr.SLA, &r.SLA},
+			durationField{"staleWhileRevalidate", jr.StaleWhileRevalidate, &r.StaleWhileRevalidate},
+		); err != nil {
+			return nil, fmt.Errorf("rule %v: %v", i, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// durationField is a duration of a rules or a routes file with its name for errors
+type durationField struct {
+	name  string
+	value string
+	field *time.Duration
+}
+
+// parseDurations sets fields to parsed non-negative durations, an empty value keeps a field
+func parseDurations(fields ...durationField) error {
+	for _, d := range fields {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("wrong %v %q", d.name, d.value)
+		}
+		*d.field = v
+	}
+	return nil
+}
+
+// Match reports whether a request with a path and query parameters matches the rule
+func (r Rule) Match(p string, query map[string][]string) bool {
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, p); !ok {
+			return false
+		}
+	}
+	for k, pattern := range r.Query {
+		matched := false
+		for _, v := range query[k] {
+			if ok, _ := path.Match(pattern, v); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a short description of the rule
+func (r Rule) String() string {
+	d := func(v time.Duration) string {
+		if v == 0 {
+			return "-"
+		}
+		return v.String()
+	}
+	p := r.Parser
+	if p == "" {
+		p = "-"
+	}
+	return fmt.Sprintf("path=%v query=%v ttl=%v sla=%v staleWhileRevalidate=%v parser=%v",
+		r.Path, r.Query, d(r.TTL), d(r.SLA), d(r.StaleWhileRevalidate), p)
+}
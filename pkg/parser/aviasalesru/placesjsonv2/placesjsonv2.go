@@ -2,8 +2,14 @@ package avaisalesru
 
 import (
 	"encoding/json"
+
+	"simpleRestCache/pkg/parser"
 )
 
+func init() {
+	parser.Register("aviasalesru/placesjsonv2", parser.Func(Parse))
+}
+
 type jsonResp struct {
 	Code        string `json:"code"`
 	Type        string `json:"type"`
@@ -21,7 +27,9 @@ type resp struct {
 func Parse(res []byte) ([]byte, error) {
 
 	jsonRes := []jsonResp{}
-	json.Unmarshal(res, &jsonRes)
+	if err := json.Unmarshal(res, &jsonRes); err != nil {
+		return nil, err
+	}
 
 	r := []resp{}
 
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Parser transforms a responce from an endpoint to a format of a client
+type Parser interface {
+	Parse(res []byte) ([]byte, error)
+}
+
+// Func is an adapter to use an ordinary function as a Parser
+type Func func(res []byte) ([]byte, error)
+
+// Parse calls f(res)
+func (f Func) Parse(res []byte) ([]byte, error) {
+	return f(res)
+}
+
+var (
+	mu      sync.RWMutex
+	parsers = make(map[string]Parser)
+)
+
+// Register makes a parser available by the provided name.
+// It is intended to be called from the init function of a parser package.
+// If Register is called twice with the same name it panics.
+func Register(name string, p Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p == nil {
+		panic("parser: Register parser is nil")
+	}
+	if _, dup := parsers[name]; dup {
+		panic("parser: Register called twice for parser " + name)
+	}
+	parsers[name] = p
+}
+
+// Get returns a registered parser by name
+func Get(name string) (Parser, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	p, ok := parsers[name]
+	if !ok {
+		return nil, fmt.Errorf("parser: unknown parser %q (forgotten import?)", name)
+	}
+	return p, nil
+}
+
+// Names returns a sorted list of names of registered parsers
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := []string{}
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,14 @@
+package passthrough
+
+import (
+	"simpleRestCache/pkg/parser"
+)
+
+func init() {
+	parser.Register("passthrough", parser.Func(Parse))
+}
+
+// Parse returns a responce from an endpoint as is
+func Parse(res []byte) ([]byte, error) {
+	return res, nil
+}
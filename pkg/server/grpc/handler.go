@@ -25,7 +25,7 @@ func NewHandler(srv *service.Service) *Handler {
 // TopN returns a responce from TopN function of the service
 func (h *Handler) TopN(ctx context.Context, req *pb.TopNRequest) (*pb.TopNReply, error) {
 	n := int(req.GetN())
-	cache, err := h.service.TopN(n)
+	cache, err := h.service.TopN(ctx, n)
 	if err != nil {
 		return &pb.TopNReply{}, err
 	}
@@ -51,6 +51,7 @@ func (h *Handler) TopN(ctx context.Context, req *pb.TopNRequest) (*pb.TopNReply,
 			RefreshDate: refDate,
 			RequestDate: reqDate,
 			AskCount:    int32(c.AskCount),
+			Header:      c.Header,
 		})
 	}
 
@@ -60,7 +61,7 @@ func (h *Handler) TopN(ctx context.Context, req *pb.TopNRequest) (*pb.TopNReply,
 // LastN returns a responce from LastN function of the service
 func (h *Handler) LastN(ctx context.Context, req *pb.LastNRequest) (*pb.LastNReply, error) {
 	n := int(req.GetN())
-	cache, err := h.service.LastN(n)
+	cache, err := h.service.LastN(ctx, n)
 	if err != nil {
 		return &pb.LastNReply{}, err
 	}
@@ -86,6 +87,7 @@ func (h *Handler) LastN(ctx context.Context, req *pb.LastNRequest) (*pb.LastNRep
 			RefreshDate: refDate,
 			RequestDate: reqDate,
 			AskCount:    int32(c.AskCount),
+			Header:      c.Header,
 		})
 	}
 
@@ -94,7 +96,7 @@ func (h *Handler) LastN(ctx context.Context, req *pb.LastNRequest) (*pb.LastNRep
 
 // All returns a responce from All function of the service
 func (h *Handler) All(ctx context.Context, req *pb.AllRequest) (*pb.AllReply, error) {
-	cache, err := h.service.All()
+	cache, err := h.service.All(ctx)
 	if err != nil {
 		return &pb.AllReply{}, err
 	}
@@ -120,12 +122,76 @@ func (h *Handler) All(ctx context.Context, req *pb.AllRequest) (*pb.AllReply, er
 			RefreshDate: refDate,
 			RequestDate: reqDate,
 			AskCount:    int32(c.AskCount),
+			Header:      c.Header,
 		})
 	}
 
 	return &pb.AllReply{Cache: pbc}, nil
 }
 
+// maxListLimit bounds a page of List so a reply fits in a gRPC message
+const maxListLimit = 1000
+
+// List returns a responce from List function of the service
+func (h *Handler) List(ctx context.Context, req *pb.ListRequest) (*pb.ListReply, error) {
+	f := service.Filter{
+		Prefix:      req.GetPrefix(),
+		Status:      int(req.GetStatus()),
+		MinAskCount: int(req.GetMinAskCount()),
+		OmitBody:    req.GetOmitBody(),
+	}
+	if req.GetMinAge() != nil {
+		d, err := timestamp.Duration(req.GetMinAge())
+		if err != nil {
+			return &pb.ListReply{}, err
+		}
+		f.MinAge = d
+	}
+	if req.GetMaxAge() != nil {
+		d, err := timestamp.Duration(req.GetMaxAge())
+		if err != nil {
+			return &pb.ListReply{}, err
+		}
+		f.MaxAge = d
+	}
+	limit := int(req.GetLimit())
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	cache, next, err := h.service.List(ctx, req.GetCursor(), limit, f)
+	if err != nil {
+		return &pb.ListReply{}, err
+	}
+
+	// convert datatypes from different packages
+	// storage.Cache -> pb.Cache
+	pbc := []*pb.Cache{}
+	for _, c := range cache {
+		refDate, err := timestamp.TimestampProto(c.RefreshDate)
+		if err != nil {
+			return &pb.ListReply{}, err
+		}
+
+		reqDate, err := timestamp.TimestampProto(c.RequestDate)
+		if err != nil {
+			return &pb.ListReply{}, err
+		}
+
+		pbc = append(pbc, &pb.Cache{
+			Request:     c.Request,
+			Responce:    c.Responce,
+			ResStatus:   int32(c.ResStatus),
+			RefreshDate: refDate,
+			RequestDate: reqDate,
+			AskCount:    int32(c.AskCount),
+			Header:      c.Header,
+		})
+	}
+
+	return &pb.ListReply{Cache: pbc, NextCursor: next}, nil
+}
+
 // Settings returns a responce from Settings function of the service
 func (h *Handler) Settings(ctx context.Context, req *pb.SettingsRequest) (*pb.SettingsReply, error) {
 	ss := h.service.Settings()
@@ -135,7 +201,7 @@ func (h *Handler) Settings(ctx context.Context, req *pb.SettingsRequest) (*pb.Se
 
 // Clean returns a responce from Clean function of the service
 func (h *Handler) Clean(ctx context.Context, req *pb.CleanRequest) (*pb.CleanReply, error) {
-	err := h.service.Clean()
+	err := h.service.Clean(ctx)
 	if err != nil {
 		return &pb.CleanReply{}, err
 	}
@@ -144,9 +210,16 @@ func (h *Handler) Clean(ctx context.Context, req *pb.CleanRequest) (*pb.CleanRep
 
 // Refresh renews all cache records
 func (h *Handler) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.RefreshReply, error) {
-	err := h.service.Refresh()
+	err := h.service.Refresh(ctx)
 	if err != nil {
 		return &pb.RefreshReply{}, err
 	}
 	return &pb.RefreshReply{}, nil
 }
+
+// Stats returns a responce from Stats function of the service
+func (h *Handler) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsReply, error) {
+	ss := h.service.Stats()
+
+	return &pb.StatsReply{Stats: ss}, nil
+}
@@ -1,8 +1,8 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/google/uuid"
 
@@ -17,25 +17,22 @@ func NewHandler(cfg *config.Config, service *service.Service) http.Handler {
 	// create router
 	m := http.NewServeMux()
 
-	// setup a router path based on APIAddr geted from config
-	handelPath := "/" + strings.Join(strings.Split(cfg.APIAddr, "/")[3:], "/")
-
-	m.HandleFunc(handelPath, func(w http.ResponseWriter, req *http.Request) {
-		handlePlaces(w, req, service)
-	})
+	// setup router paths based on routes geted from config
+	for _, r := range cfg.Routes {
+		m.HandleFunc(r.Prefix, func(w http.ResponseWriter, req *http.Request) {
+			handlePlaces(w, req, cfg, service)
+		})
+	}
 
 	return m
 }
 
-func handlePlaces(w http.ResponseWriter, req *http.Request, srv *service.Service) {
+func handlePlaces(w http.ResponseWriter, req *http.Request, cfg *config.Config, srv *service.Service) {
 	switch req.Method {
 	case "GET":
-		// get a query part of a URL
-		i := strings.Index(req.URL.String(), "?")
-		rq := ""
-		if i >= 0 {
-			rq = req.URL.String()[i:]
-		}
+		// a normalized path with a query part of a URL and key headers is a cache key
+		// the path namespaces keys of different routes
+		rq := srv.Key(req.URL, req.Header)
 
 		id := uuid.New().String()
 
@@ -44,17 +41,36 @@ func handlePlaces(w http.ResponseWriter, req *http.Request, srv *service.Service
 			"rq": rq,
 		}).Info("New request is received")
 
-		rs, sc, err := srv.HandelRequest(service.Request{ID: id, Q: rq})
+		// a client which accepts gzip can get a compressed cache record as is
+		ctx := service.WithAcceptEncoding(req.Context(), service.ParseAcceptEncoding(req.Header.Get("Accept-Encoding")))
+
+		rp, err := srv.HandelRequest(ctx, service.Request{ID: id, Q: rq, PathQuery: srv.PathQuery(req.URL)})
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("500 Internal Server Error"))
+			// a status of a reply tells an unavailable or a broken endpoint from a failure of the service
+			status := rp.Status
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(fmt.Sprintf("%d %v", status, http.StatusText(status))))
 			w.Write([]byte("\n"))
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		w.WriteHeader(sc)
-		w.Write(rs)
+		// a cache hit has the same headers as a miss
+		for k, v := range rp.Header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		for _, h := range cfg.KeyHeaders {
+			w.Header().Add("Vary", h)
+		}
+		if rp.Encoding != "" {
+			w.Header().Set("Content-Encoding", rp.Encoding)
+		}
+		w.WriteHeader(rp.Status)
+		w.Write(rp.Body)
 	default:
 		log.Info("Not GET request received")
 		w.WriteHeader(http.StatusBadRequest)
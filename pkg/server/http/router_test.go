@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"simpleRestCache/pkg/config"
+	_ "simpleRestCache/pkg/parser/aviasalesru/placesjsonv2"
+	_ "simpleRestCache/pkg/parser/passthrough"
+	"simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/inmem"
+)
+
+func TestMalformedResponceIsNotCached(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"code":"MOW",`))
+	}))
+	defer upstream.Close()
+
+	stored := service.Cache{
+		Request:   "/places?term=mos",
+		Responce:  `[{"code":"MOW"}]`,
+		ResStatus: 200,
+	}
+	tests := []struct {
+		name     string
+		stored   []service.Cache
+		wantCode int
+		wantBody string
+	}{
+		{"a stored record is returned", []service.Cache{stored}, 200, stored.Responce},
+		{"an error without a record", nil, http.StatusBadGateway, "502 Bad Gateway\n" + service.ErrCannotParseMessage.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				ExpiredPeriod:     time.Nanosecond, // a stored record is expired at once
+				SLA:               time.Second,
+				StatFlushInterval: time.Hour,
+				CacheableStatuses: []int{200},
+				Parser:            "aviasalesru/placesjsonv2",
+				Routes:            []config.Route{{Prefix: "/places", Upstream: upstream.URL}},
+			}
+			st := inmem.New(cfg)
+			ctx := context.Background()
+			var refreshed time.Time
+			for _, c := range tt.stored {
+				if err := st.SaveCache(ctx, c); err != nil {
+					t.Fatal(err)
+				}
+				c, _ = st.Cache(ctx, c.Request)
+				refreshed = c.RefreshDate
+			}
+			srv := service.New(cfg, st)
+			defer srv.Close()
+			h := httptest.NewServer(NewHandler(cfg, srv))
+			defer h.Close()
+
+			resp, err := http.Get(h.URL + "/places?term=mos")
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantCode || string(body) != tt.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.wantCode, tt.wantBody)
+			}
+
+			c, err := st.Cache(ctx, stored.Request)
+			if len(tt.stored) == 0 {
+				if err != service.ErrCacheNotFound {
+					t.Errorf("a malformed responce is cached: %+v, %v", c, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Responce != stored.Responce || !c.RefreshDate.Equal(refreshed) {
+				t.Errorf("a stored record is overwritten: %+v", c)
+			}
+		})
+	}
+}
+
+func TestUpstreamGetsClientQuery(t *testing.T) {
+	queries := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.RawQuery
+		w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		ExpiredPeriod:     time.Hour,
+		SLA:               time.Second,
+		StatFlushInterval: time.Hour,
+		CacheableStatuses: []int{200},
+		Parser:            "passthrough",
+		KeyDropParams:     []string{"utm_*"},
+		Routes:            []config.Route{{Prefix: "/places", Upstream: upstream.URL}},
+	}
+	st := inmem.New(cfg)
+	srv := service.New(cfg, st)
+	defer srv.Close()
+	h := httptest.NewServer(NewHandler(cfg, srv))
+	defer h.Close()
+
+	resp, err := http.Get(h.URL + "/places?Term=mo%73&utm_source=x&Locale=ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if q := <-queries; q != "Term=mo%73&Locale=ru" {
+		t.Errorf("endpoint got %q", q)
+	}
+	if _, err := st.Cache(context.Background(), "/places?locale=ru&term=mos"); err != nil {
+		t.Errorf("a responce is not cached under a key: %v", err)
+	}
+}
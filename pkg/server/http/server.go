@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"time"
 
@@ -11,14 +12,22 @@ import (
 	"simpleRestCache/pkg/service"
 )
 
+// shutdownTimeout bounds waiting for in-flight requests on Close
+const shutdownTimeout = 10 * time.Second
+
 // Server represents HTTP Server
 type Server struct {
 	server http.Server
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New creates an instance of the Server
 func New(cfg *config.Config, service *service.Service) *Server {
 	s := &Server{}
+	// all requests' contexts are derived from ctx
+	// so they are canceled when the server could not stop gracefully
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	m := NewHandler(cfg, service)
 
@@ -27,6 +36,9 @@ func New(cfg *config.Config, service *service.Service) *Server {
 		Handler:      m,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return s.ctx
+		},
 	}
 	log.Info("HTTP server has been initialized")
 
@@ -42,5 +54,14 @@ func (s *Server) Run() error {
 // Close stops HTTP Server
 func (s *Server) Close() {
 	log.Info("Stopping HTTP server ", s.server.Addr)
-	s.server.Shutdown(context.Background())
+	// in-flight requests are finished first, requests still running
+	// after the timeout are canceled with their requests to the endpoint
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Warn("HTTP server has not finished in-flight requests")
+	}
+	s.cancel()
 }
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"simpleRestCache/pkg/config"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker is a circuit breaker around the endpoint.
+// It opens when a rate of failed or slow requests in a window of last requests
+// reaches a threshold. After a timeout it lets one probe request through
+// and closes again if the probe succeeds.
+type breaker struct {
+	mu       sync.Mutex
+	state    breakerState
+	openedAt time.Time
+	probe    bool // a probe request is in flight in half-open state
+
+	// a ring of outcomes of last requests, true means failure
+	window   []bool
+	pos      int
+	count    int
+	failures int
+
+	errorRate   float64
+	latency     time.Duration
+	minRequests int
+	openTimeout time.Duration
+}
+
+func newBreaker(cfg *config.Config) *breaker {
+	return &breaker{
+		window:      make([]bool, cfg.BreakerWindow),
+		errorRate:   cfg.BreakerErrorRate,
+		latency:     breakerLatency(cfg),
+		minRequests: cfg.BreakerMinRequests,
+		openTimeout: cfg.BreakerOpenTimeout,
+	}
+}
+
+// breakerLatency returns a latency after which a request is counted as failed
+func breakerLatency(cfg *config.Config) time.Duration {
+	if cfg.BreakerLatency == 0 {
+		return cfg.SLA
+	}
+	return cfg.BreakerLatency
+}
+
+func (b *breaker) enabled() bool {
+	return b.errorRate > 0 && len(b.window) > 0
+}
+
+// Allow reports whether a request to the endpoint may be sent.
+// Every allowed request must be followed by Done or Cancel.
+func (b *breaker) Allow() bool {
+	if !b.enabled() {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(stateHalfOpen)
+		b.probe = true
+		return true
+	case stateHalfOpen:
+		if b.probe {
+			return false
+		}
+		b.probe = true
+		return true
+	}
+	return true
+}
+
+// Done records an outcome of a request to the endpoint
+func (b *breaker) Done(latency time.Duration, failed bool) {
+	if !b.enabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed = failed || latency > b.latency
+
+	switch b.state {
+	case stateHalfOpen:
+		b.probe = false
+		if failed {
+			b.setState(stateOpen)
+		} else {
+			b.setState(stateClosed)
+		}
+	case stateClosed:
+		if b.count == len(b.window) {
+			if b.window[b.pos] {
+				b.failures--
+			}
+		} else {
+			b.count++
+		}
+		b.window[b.pos] = failed
+		if failed {
+			b.failures++
+		}
+		b.pos = (b.pos + 1) % len(b.window)
+
+		if b.count >= b.minRequests && float64(b.failures)/float64(b.count) >= b.errorRate {
+			b.setState(stateOpen)
+		}
+	}
+}
+
+// Cancel releases a request which was aborted before it got an outcome
+func (b *breaker) Cancel() {
+	if !b.enabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateHalfOpen {
+		b.probe = false
+	}
+}
+
+// setState switches the breaker to a new state. b.mu must be held.
+func (b *breaker) setState(state breakerState) {
+	log.WithFields(log.Fields{
+		"from":     b.state,
+		"to":       state,
+		"failures": b.failures,
+		"requests": b.count,
+	}).Warn("Circuit breaker changed its state")
+
+	b.state = state
+	if state == stateOpen {
+		b.openedAt = time.Now()
+	}
+	// start a new window in every state
+	b.pos, b.count, b.failures = 0, 0, 0
+	for i := range b.window {
+		b.window[i] = false
+	}
+}
+
+// String returns a state of the breaker with a statistic of the current window
+func (b *breaker) String() string {
+	if !b.enabled() {
+		return "disabled"
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == stateOpen {
+		return fmt.Sprintf("%v since %v", b.state, b.openedAt.Format("2006-01-02 15:04:05"))
+	}
+	return fmt.Sprintf("%v (%v of %v requests failed)", b.state, b.failures, b.count)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+type acceptEncodingKey struct{}
+
+// WithAcceptEncoding returns a context with content encodings which a client accepts.
+// A storage returns a compressed record as is if its encoding is accepted.
+func WithAcceptEncoding(ctx context.Context, encodings []string) context.Context {
+	return context.WithValue(ctx, acceptEncodingKey{}, encodings)
+}
+
+// Accepts reports whether a client accepts an encoding according to a context
+func Accepts(ctx context.Context, encoding string) bool {
+	encodings, _ := ctx.Value(acceptEncodingKey{}).([]string)
+	for _, e := range encodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAcceptEncoding returns encodings listed in an Accept-Encoding header
+// except ones with zero quality
+func ParseAcceptEncoding(header string) []string {
+	encodings := []string{}
+	for _, v := range strings.Split(header, ",") {
+		parts := strings.Split(v, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		accepted := true
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				accepted = err == nil && q > 0
+			}
+		}
+		if accepted {
+			encodings = append(encodings, name)
+		}
+	}
+	return encodings
+}
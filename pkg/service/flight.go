@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// call is an in-flight or completed request to the endpoint
+type call struct {
+	done   chan struct{}
+	resp   APIResp
+	dups   int
+	refs   int
+	cancel context.CancelFunc
+}
+
+// flight coalesces concurrent requests with the same key
+// so only one request to the endpoint is in flight per key
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func newFlight() *flight {
+	return &flight{
+		calls: make(map[string]*call),
+	}
+}
+
+// Do executes fn once for all concurrent callers with the same key.
+// Do returns a channel which receives the result of fn and
+// a flag that shows that the result is shared with other callers.
+// fn gets its own context which is canceled when all callers are gone.
+func (f *flight) Do(ctx context.Context, key string, fn func(ctx context.Context) APIResp) <-chan flightResult {
+	ch := make(chan flightResult, 1)
+
+	f.mu.Lock()
+	c, ok := f.calls[key]
+	if ok {
+		c.dups++
+		c.refs++
+	} else {
+		fnCtx, cancel := context.WithCancel(context.Background())
+		c = &call{
+			done:   make(chan struct{}),
+			refs:   1,
+			cancel: cancel,
+		}
+		f.calls[key] = c
+		go f.run(fnCtx, key, c, fn)
+	}
+	f.mu.Unlock()
+
+	go func() {
+		select {
+		case <-c.done:
+			f.mu.Lock()
+			shared := c.dups > 0
+			f.mu.Unlock()
+			ch <- flightResult{resp: c.resp, shared: shared}
+		case <-ctx.Done():
+			f.mu.Lock()
+			c.refs--
+			if c.refs == 0 {
+				// nobody waits for the result so abort fn
+				c.cancel()
+				if f.calls[key] == c {
+					delete(f.calls, key)
+				}
+			}
+			f.mu.Unlock()
+			ch <- flightResult{resp: APIResp{
+				Resp:   []byte{},
+				Status: http.StatusServiceUnavailable,
+				Err:    ctx.Err(),
+			}}
+		}
+	}()
+	return ch
+}
+
+func (f *flight) run(ctx context.Context, key string, c *call, fn func(ctx context.Context) APIResp) {
+	c.resp = fn(ctx)
+
+	f.mu.Lock()
+	if f.calls[key] == c {
+		delete(f.calls, key)
+	}
+	f.mu.Unlock()
+
+	c.cancel()
+	close(c.done)
+}
+
+// flightResult is a result of an execution of a flight
+type flightResult struct {
+	resp   APIResp
+	shared bool
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// freshness describes caching directives of a responce from the endpoint
+type freshness struct {
+	noStore   bool
+	maxAge    time.Duration
+	hasMaxAge bool
+}
+
+// parseFreshness reads Cache-Control and Expires headers of a responce.
+// simpleRESTcache is a shared cache so s-maxage has priority over max-age
+// and private responces are not stored.
+func parseFreshness(h http.Header) freshness {
+	f := freshness{}
+
+	directives := map[string]string{}
+	for _, line := range h["Cache-Control"] {
+		for _, d := range strings.Split(line, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, value := d, ""
+			if i := strings.Index(d, "="); i >= 0 {
+				name, value = d[:i], strings.Trim(strings.TrimSpace(d[i+1:]), "\"")
+			}
+			directives[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+
+	if _, ok := directives["no-store"]; ok {
+		f.noStore = true
+		return f
+	}
+	if _, ok := directives["private"]; ok {
+		f.noStore = true
+		return f
+	}
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			if sec, err := strconv.ParseInt(v, 10, 64); err == nil && sec >= 0 {
+				f.maxAge = time.Duration(sec) * time.Second
+				f.hasMaxAge = true
+				return f
+			}
+		}
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		// a responce must be revalidated before every use
+		f.hasMaxAge = true
+		return f
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		f.hasMaxAge = true
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// an invalid date means already expired
+			return f
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		if expires.After(date) {
+			f.maxAge = expires.Sub(date)
+		}
+	}
+	return f
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// hits collects statistic of cache hits in memory
+// and writes it to the storage in batches, one increment per request.
+type hits struct {
+	mu      sync.Mutex
+	pending map[string]Stat
+
+	storage  Storage
+	interval time.Duration
+	failed   func(req Request, err error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newHits(storage Storage, interval time.Duration, failed func(req Request, err error)) *hits {
+	h := &hits{
+		pending:  make(map[string]Stat),
+		storage:  storage,
+		interval: interval,
+		failed:   failed,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// add counts a hit of a request
+func (h *hits) add(req Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := h.pending[req.Q]
+	st.Request = req.Q
+	st.Count++
+	st.RequestDate = time.Now()
+	h.pending[req.Q] = st
+}
+
+// run flushes statistic on every interval until Close
+func (h *hits) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.flush(context.Background(), true)
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// flush writes pending statistic to the storage.
+// If retry is set statistic which was not written is kept for the next flush.
+func (h *hits) flush(ctx context.Context, retry bool) {
+	h.mu.Lock()
+	pending := h.pending
+	h.pending = make(map[string]Stat)
+	h.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	written := 0
+	for _, st := range pending {
+		err := h.storage.UpdateStat(ctx, st)
+		if err == nil {
+			written++
+			continue
+		}
+		h.failed(Request{Q: st.Request}, err)
+		if retry {
+			h.restore(st)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"records": written,
+		"failed":  len(pending) - written,
+	}).Info("Statistics has been updated")
+}
+
+// restore returns statistic which was not written to pending one
+func (h *hits) restore(st Stat) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.pending[st.Request]
+	if !ok {
+		h.pending[st.Request] = st
+		return
+	}
+	p.Count += st.Count
+	if st.RequestDate.After(p.RequestDate) {
+		p.RequestDate = st.RequestDate
+	}
+	h.pending[st.Request] = p
+}
+
+// Close stops periodic flushes and writes all pending statistic
+func (h *hits) Close(ctx context.Context) {
+	close(h.stop)
+	<-h.done
+	h.flush(ctx, false)
+}
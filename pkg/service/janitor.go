@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// janitor periodically deletes records which were neither requested nor refreshed
+// during a retention period. Records are deleted in batches so a database
+// is never locked for long.
+type janitor struct {
+	storage   Storage
+	retention time.Duration
+	interval  time.Duration
+	batch     int
+
+	purged int64 // deleted records since start
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newJanitor(storage Storage, retention, interval time.Duration, batch int) *janitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &janitor{
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+		batch:     batch,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go j.run()
+	log.WithFields(log.Fields{
+		"retention": retention,
+		"interval":  interval,
+		"batch":     batch,
+	}).Info("Janitor has been started")
+	return j
+}
+
+// run purges records on every interval until Close
+func (j *janitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.purge(j.ctx)
+		case <-j.ctx.Done():
+			return
+		}
+	}
+}
+
+// purge deletes dead records batch by batch until there are no more of them
+func (j *janitor) purge(ctx context.Context) {
+	before := time.Now().Add(-j.retention)
+	deleted := 0
+	batches := 0
+	for ctx.Err() == nil {
+		n, err := j.storage.Purge(ctx, before, j.batch)
+		deleted += n
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Error("Cannot purge cache records in Storage")
+			}
+			break
+		}
+		batches++
+		if n < j.batch {
+			break
+		}
+	}
+	atomic.AddInt64(&j.purged, int64(deleted))
+
+	log.WithFields(log.Fields{
+		"records": deleted,
+		"batches": batches,
+		"before":  before,
+	}).Info("Cache records have been purged")
+}
+
+// Close stops the janitor, a running purge is aborted
+func (j *janitor) Close() {
+	j.cancel()
+	<-j.done
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"simpleRestCache/pkg/config"
+)
+
+// A cache key is a path, sorted query parameters and selected request headers after "#":
+//
+//	/places?locale=ru&term=mos#accept-language=ru
+//
+// A fragment is never sent by clients so it cannot be confused with a path or a query.
+const keyHeaderSep = "#"
+
+// KeyBuilder composes cache keys of requests according to key-drop-params and key-headers
+type KeyBuilder struct {
+	dropParams []string // patterns of lower cased names of query parameters
+	headers    []string // canonical names of request headers
+	// a path of the default route, the first versions keyed records by a query of it
+	legacyPrefix string
+}
+
+// NewKeyBuilder returns a KeyBuilder of configured key settings
+func NewKeyBuilder(cfg *config.Config) KeyBuilder {
+	b := KeyBuilder{
+		dropParams:   cfg.KeyDropParams,
+		headers:      cfg.KeyHeaders,
+		legacyPrefix: "/",
+	}
+	if r, err := config.DefaultRoute(cfg.APIAddr); err == nil {
+		b.legacyPrefix = r.Prefix
+	}
+	return b
+}
+
+// Key returns a cache key of a request. Names of query parameters are lower cased,
+// parameters are sorted and encoded the same way and parameters matched by
+// key-drop-params are dropped. Request headers of key-headers are appended to a key.
+// A query which cannot be parsed is kept as is, so different requests never share a key.
+func (b KeyBuilder) Key(u *url.URL, h http.Header) string {
+	var header http.Header
+	for _, k := range b.headers {
+		if v, ok := h[k]; ok {
+			if header == nil {
+				header = http.Header{}
+			}
+			header[k] = v
+		}
+	}
+	return b.key(u.Path, u.RawQuery, header)
+}
+
+// PathQuery returns a local path with a query of a request which is sent to the endpoint.
+// Unlike a key it keeps names, order and encoding of query parameters,
+// only parameters matched by key-drop-params are dropped.
+func (b KeyBuilder) PathQuery(u *url.URL) string {
+	pathQuery := u.EscapedPath()
+	if _, ok := b.query(u.RawQuery); !ok {
+		// a malformed query is kept as is, as in a key
+		if u.RawQuery != "" {
+			pathQuery += "?" + u.RawQuery
+		}
+		return pathQuery
+	}
+	pairs := []string{}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		k := pair
+		if i := strings.Index(pair, "="); i >= 0 {
+			k = pair[:i]
+		}
+		// the query is well formed so every name is unescaped
+		k, _ = url.QueryUnescape(k)
+		if b.dropParam(strings.ToLower(k)) {
+			continue
+		}
+		pairs = append(pairs, pair)
+	}
+	if len(pairs) > 0 {
+		pathQuery += "?" + strings.Join(pairs, "&")
+	}
+	return pathQuery
+}
+
+// Normalize returns a key of a stored key which could be composed by older settings.
+// A query-only key of the first versions gets a path of the default route.
+func (b KeyBuilder) Normalize(key string) string {
+	if !strings.HasPrefix(key, "/") {
+		key = b.legacyPrefix + key
+	}
+	pathQuery, header := splitKeyHeader(key)
+	p, rawQuery := splitKey(pathQuery)
+	if u, err := url.PathUnescape(p); err == nil {
+		p = u
+	}
+	return b.key(p, rawQuery, header)
+}
+
+func (b KeyBuilder) key(p, rawQuery string, h http.Header) string {
+	key := (&url.URL{Path: p}).EscapedPath()
+	if query, ok := b.query(rawQuery); ok {
+		if len(query) > 0 {
+			key += "?" + query.Encode()
+		}
+	} else if rawQuery != "" {
+		key += "?" + rawQuery
+	}
+
+	header := url.Values{}
+	for _, k := range b.headers {
+		if v, ok := h[k]; ok {
+			header.Set(strings.ToLower(k), strings.TrimSpace(strings.Join(v, ", ")))
+		}
+	}
+	if len(header) > 0 {
+		key += keyHeaderSep + header.Encode()
+	}
+	return key
+}
+
+// query parses a raw query to parameters of a key, ok is false if the query is malformed.
+// Values of names which differ only in case are merged in order of the query.
+func (b KeyBuilder) query(rawQuery string) (url.Values, bool) {
+	query := url.Values{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		if strings.Contains(pair, ";") {
+			// url.ParseQuery rejects it too
+			return nil, false
+		}
+		k, v := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			k, v = pair[:i], pair[i+1:]
+		}
+		k, err := url.QueryUnescape(k)
+		if err != nil {
+			return nil, false
+		}
+		v, err = url.QueryUnescape(v)
+		if err != nil {
+			return nil, false
+		}
+		k = strings.ToLower(k)
+		if b.dropParam(k) {
+			continue
+		}
+		query[k] = append(query[k], v)
+	}
+	return query, true
+}
+
+// dropParam reports whether a query parameter is not a part of a key
+func (b KeyBuilder) dropParam(name string) bool {
+	for _, p := range b.dropParams {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitKeyHeader splits a cache key to a local path with a query and request headers
+func splitKeyHeader(key string) (string, http.Header) {
+	i := strings.Index(key, keyHeaderSep)
+	if i < 0 {
+		return key, nil
+	}
+	values, _ := url.ParseQuery(key[i+1:])
+	header := http.Header{}
+	for k, v := range values {
+		header[http.CanonicalHeaderKey(k)] = v
+	}
+	return key[:i], header
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"simpleRestCache/pkg/config"
+)
+
+func TestNormalize(t *testing.T) {
+	b := NewKeyBuilder(&config.Config{
+		APIAddr:       "https://places.aviasales.ru/v2/places.json",
+		KeyDropParams: []string{"utm_*"},
+	})
+	for _, tc := range []struct {
+		key, want string
+	}{
+		{"/v2/places.json?term=a", "/v2/places.json?term=a"},
+		{"/other/x?B=2&a=1", "/other/x?a=1&b=2"},
+		{"/v2/places.json?Term=a&utm_source=x", "/v2/places.json?term=a"},
+		// keys of the first versions are a query of the default route
+		{"?Term=a&locale=ru", "/v2/places.json?locale=ru&term=a"},
+		{"?a;b", "/v2/places.json?a;b"},
+	} {
+		if got := b.Normalize(tc.key); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"simpleRestCache/pkg/config"
+)
+
+// policy contains cache settings for a request
+type policy struct {
+	ttl time.Duration
+	sla time.Duration
+	swr time.Duration
+	// name of a parser for a responce
+	parser string
+	// route to the endpoint, ok is false if no route matches a request
+	route config.Route
+	ok    bool
+}
+
+// policy returns cache settings and a route for a cache key.
+// Settings of a route of the key override global settings.
+// The first rule matched the key overrides settings of the route.
+// A policy is computed once per request and passed down.
+func (s *Service) policy(key string) policy {
+	p := policy{
+		ttl: s.cfg.ExpiredPeriod,
+		sla: s.cfg.SLA,
+		swr: s.cfg.StaleWhileRevalidate,
+
+		parser: s.cfg.Parser,
+	}
+
+	p.route, p.ok = s.route(key)
+	if p.ok {
+		p.override(p.route.TTL, p.route.SLA, p.route.StaleWhileRevalidate, p.route.Parser)
+	}
+
+	if len(s.cfg.Rules) == 0 {
+		return p
+	}
+
+	path, rawQuery := splitKey(key)
+	query, _ := url.ParseQuery(rawQuery)
+	for _, r := range s.cfg.Rules {
+		if r.Match(path, query) {
+			p.override(r.TTL, r.SLA, r.StaleWhileRevalidate, r.Parser)
+			break
+		}
+	}
+	return p
+}
+
+// override replaces settings by non-zero values
+func (p *policy) override(ttl, sla, swr time.Duration, parser string) {
+	if ttl != 0 {
+		p.ttl = ttl
+	}
+	if sla != 0 {
+		p.sla = sla
+	}
+	if swr != 0 {
+		p.swr = swr
+	}
+	if parser != "" {
+		p.parser = parser
+	}
+}
+
+// route returns a route of a cache key. The longest matched prefix wins.
+func (s *Service) route(key string) (config.Route, bool) {
+	path, _ := splitKey(key)
+	best := -1
+	for i, r := range s.cfg.Routes {
+		if r.Match(path) && (best < 0 || len(r.Prefix) > len(s.cfg.Routes[best].Prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return config.Route{}, false
+	}
+	return s.cfg.Routes[best], true
+}
+
+// splitKey splits a cache key to a local path and a raw query, request headers of a key are skipped
+func splitKey(key string) (string, string) {
+	if i := strings.Index(key, keyHeaderSep); i >= 0 {
+		key = key[:i]
+	}
+	if i := strings.Index(key, "?"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,48 +17,148 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"simpleRestCache/pkg/config"
-	parser "simpleRestCache/pkg/parser/aviasalesru/placesjsonv2"
+	"simpleRestCache/pkg/parser"
 )
 
-// Storage declares methods that the real storage object should implement
+// Storage declares methods that the real storage object should implement.
+// Cache returns ErrCacheNotFound if there is no record for a request.
+// Other errors mean a failure of the storage.
+// Purge deletes at most limit records which RequestDate and RefreshDate are both before
+// a time and returns a number of deleted records.
+// List returns a page of records which match a filter and a cursor of the next page,
+// an empty cursor starts a listing and an empty next cursor ends it.
 type Storage interface {
-	Cache(r string) Cache
-	SaveCache(c Cache)
-	Clean() error
-	UpdateStat(req Request)
-	TopN(n int) ([]Cache, error)
-	LastN(n int) ([]Cache, error)
-	All() ([]Cache, error)
+	Cache(ctx context.Context, r string) (Cache, error)
+	SaveCache(ctx context.Context, c Cache) error
+	Clean(ctx context.Context) error
+	Purge(ctx context.Context, before time.Time, limit int) (int, error)
+	UpdateStat(ctx context.Context, st Stat) error
+	TopN(ctx context.Context, n int) ([]Cache, error)
+	LastN(ctx context.Context, n int) ([]Cache, error)
+	List(ctx context.Context, cursor string, limit int, f Filter) ([]Cache, string, error)
+}
+
+// StatsReporter is implemented by a Storage which reports its own statistic
+type StatsReporter interface {
+	Stats() []string
 }
 
 // Request represents a request
 type Request struct {
 	ID string // inner system ID
-	Q  string // query body
+	Q  string // cache key, see Key
+	// a local path with a query sent to the endpoint, see PathQuery.
+	// A path and a query of Q are sent if it is empty.
+	PathQuery string
+}
+
+// Stat is statistic of hits of a cache record collected since the last update
+type Stat struct {
+	Request     string
+	Count       int       // is added to AskCount
+	RequestDate time.Time // the last hit
+}
+
+// Filter selects records of a listing, zero fields match any record
+type Filter struct {
+	Prefix      string        // of a request
+	Status      int           // ResStatus
+	MinAge      time.Duration // since RefreshDate
+	MaxAge      time.Duration // since RefreshDate
+	MinAskCount int
+	OmitBody    bool // Responce of listed records is empty
+}
+
+// Match reports whether a record passes the filter at a moment
+func (f Filter) Match(c Cache, now time.Time) bool {
+	if !strings.HasPrefix(c.Request, f.Prefix) {
+		return false
+	}
+	if f.Status != 0 && c.ResStatus != f.Status {
+		return false
+	}
+	if f.MinAge != 0 && c.RefreshDate.After(now.Add(-f.MinAge)) {
+		return false
+	}
+	if f.MaxAge != 0 && c.RefreshDate.Before(now.Add(-f.MaxAge)) {
+		return false
+	}
+	return c.AskCount >= f.MinAskCount
 }
 
 // Cache represents cache
 type Cache struct {
-	Request     string
-	Responce    string
-	ResStatus   int
-	RefreshDate time.Time
-	RequestDate time.Time
-	AskCount    int
-	Err         error
+	Request      string
+	Responce     string
+	ResStatus    int
+	RefreshDate  time.Time
+	RequestDate  time.Time
+	AskCount     int
+	ETag         string
+	LastModified string
+	ExpireDate   time.Time // set by caching headers of the endpoint
+	Encoding     string    // content encoding of Responce, empty means identity
+	// allow-listed headers of a responce of the endpoint by canonical names,
+	// several values of a header are joined by ", "
+	Header map[string]string
+}
+
+// EncodeHeader encodes headers of a cache record as JSON for storages
+// which keep them in a string, no headers is an empty string
+func EncodeHeader(h map[string]string) string {
+	if len(h) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(h)
+	return string(b)
+}
+
+// DecodeHeader decodes headers of a cache record encoded by EncodeHeader
+func DecodeHeader(v string) map[string]string {
+	if v == "" {
+		return nil
+	}
+	h := map[string]string{}
+	if err := json.Unmarshal([]byte(v), &h); err != nil {
+		return nil
+	}
+	return h
+}
+
+// Reply is a responce to a client
+type Reply struct {
+	Body     []byte
+	Status   int
+	Encoding string // content encoding of Body, empty means identity
+	Header   map[string]string
 }
 
 // APIResp is a responce from the endpoint
 type APIResp struct {
-	Resp   []byte
-	Status int
-	Err    error
+	Resp         []byte
+	Status       int
+	Err          error
+	ETag         string
+	LastModified string
+	Header       map[string]string
+	NotModified  bool
+	fresh        freshness
 }
 
 // Service is a central component of the system. It contains all business logic.
 type Service struct {
-	storage Storage
-	cfg     *config.Config
+	storage  Storage
+	cfg      *config.Config
+	flight   *flight
+	upstream *upstream
+	breakers map[string]*breaker // by route prefix
+	hits     *hits
+	janitor  *janitor
+	keys     KeyBuilder
+
+	// failures of the storage
+	readErrors  int64
+	writeErrors int64
 }
 
 var (
@@ -66,153 +171,486 @@ var (
 	// ErrEndpointAPIUnavailable arise if something happened with a endpoint
 	ErrEndpointAPIUnavailable = errors.New("Endpoint API is unavailable")
 
+	// ErrRouteNotFound arise when there is no route to an endpoint for a request
+	ErrRouteNotFound = errors.New("Route is not found")
+
 	// ErrStorageUnavailable arise when something wrong with a storage subsystem
 	ErrStorageUnavailable = errors.New("Storage subsystem is unavailable")
+
+	// errCircuitOpen arise when a request is not sent to the endpoint by circuit breaker
+	errCircuitOpen = errors.New("Circuit breaker is open")
 )
 
 // New retunrs new Service
 func New(cfg *config.Config, store Storage) *Service {
 	s := &Service{
-		storage: store,
-		cfg:     cfg,
+		storage:  store,
+		cfg:      cfg,
+		flight:   newFlight(),
+		upstream: newUpstream(cfg),
+		breakers: make(map[string]*breaker),
+		keys:     NewKeyBuilder(cfg),
+	}
+	// every endpoint has its own circuit breaker
+	for _, r := range cfg.Routes {
+		s.breakers[r.Prefix] = newBreaker(cfg)
+	}
+	s.hits = newHits(store, cfg.StatFlushInterval, s.writeFailed)
+	if cfg.PurgeRetention > 0 {
+		s.janitor = newJanitor(store, cfg.PurgeRetention, cfg.PurgeInterval, cfg.PurgeBatch)
 	}
 	return s
 }
 
+// Close stops the janitor and writes all pending statistic to the storage
+func (s *Service) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if s.janitor != nil {
+		s.janitor.Close()
+	}
+	s.hits.Close(ctx)
+	log.Info("Service has been closed")
+}
+
 // HandelRequest redirects request to endpoint and also stores a responce in cache
-// HandelRequest returns Parsed/Unparsed, Status Code, error
-func (s *Service) HandelRequest(req Request) ([]byte, int, error) {
+// HandelRequest returns Parsed/Unparsed responce with Status Code, error
+// A request to endpoint is aborted when ctx is canceled
+// A cached responce can be returned compressed if ctx accepts its encoding, see WithAcceptEncoding
+func (s *Service) HandelRequest(ctx context.Context, req Request) (Reply, error) {
+
+	ctxAPI, cancelAPI := context.WithCancel(ctx)
+	defer cancelAPI()
 
-	ctxAPI, cancelAPI := context.WithCancel(context.Background())
+	// cache settings for the request
+	p := s.policy(req.Q)
 
 	// two channal for interact with two parallel request
-	chRespStorage := make(chan Cache)
-	chRespAPI := make(chan APIResp)
+	// channels are buffered so the gorutines never block after a return
+	chRespStorage := make(chan Cache, 1)
+	chRespAPI := make(chan APIResp, 1)
 
 	// send a request to Storage
+	// a failure of Storage is the same as a missing record, Endpoint answers instead
 	go func() {
-		chRespStorage <- s.storage.Cache(req.Q)
-		close(chRespStorage)
+		c, err := s.storage.Cache(ctxAPI, req.Q)
+		if err != nil {
+			if err != ErrCacheNotFound && ctxAPI.Err() == nil {
+				s.readFailed(req, err)
+			}
+			c = Cache{}
+		}
+		chRespStorage <- c
 	}()
 
 	// send a request to Endpoint
 	go func() {
 		// wait time
-		w := s.cfg.SLA / 10
+		w := p.sla / 10
 		if w > 10*time.Millisecond {
 			w = 10 * time.Millisecond
 		}
 		select {
 		case <-time.After(w):
-			chRespAPI <- s.requestToAPI(req)
+			res := <-s.fetch(ctxAPI, req, p)
+			if res.shared {
+				log.WithFields(log.Fields{
+					"id": req.ID,
+				}).Info("Shared a request to Endpoint with concurrent requests")
+			}
+			chRespAPI <- res.resp
 		case <-ctxAPI.Done():
 		}
-		close(chRespAPI)
 	}()
 
-	sla := time.NewTimer(s.cfg.SLA)
-	respStorage := Cache{}
+	sla := time.NewTimer(p.sla)
+	respStorage := Cache{} // an empty Request means there is no record
+	storageDone := false
 	for {
 		select {
 		case respStorage = <-chRespStorage: // got a responce from Storage
-			if respStorage != (Cache{}) && respStorage.Err == nil {
+			storageDone = true
+			if respStorage.Request != "" {
 				log.WithFields(log.Fields{
 					"id": req.ID,
 				}).Info("Find a responce in cache...")
 				// if cache is not expired immediately return it
-				if time.Since(respStorage.RefreshDate) <= s.cfg.ExpiredPeriod {
+				if time.Now().Before(s.expiry(respStorage, p)) {
 					log.WithFields(log.Fields{
 						"id": req.ID,
 					}).Info("...and cache is not expired")
 
 					// cancel API request
+					// if the request has already been sent and other requests
+					// wait for it a responce will be saved to Storage by fetch
 					cancelAPI()
-					// start gorutine to wait a responce from API for savint it to Storage
-					go func(req Request, chRespAPI chan APIResp) {
-						select {
-						case respAPI, ok := <-chRespAPI:
-							if ok {
-								log.WithFields(log.Fields{
-									"id": req.ID,
-								}).Info("Did not have time to stop the request to the Endpoin. Refresh cache.")
-								s.storage.SaveCache(Cache{
-									Request:   req.Q,
-									Responce:  string(respAPI.Resp),
-									ResStatus: respAPI.Status,
-								})
-							}
-						case <-time.After(s.cfg.SLA * 2):
-						}
-					}(req, chRespAPI)
 
 					// update statistic
-					go s.storage.UpdateStat(req)
+					s.hits.add(req)
 
 					log.WithFields(log.Fields{
 						"id": req.ID,
 					}).Info("...returning a cache record for a responce")
-					return []byte(respStorage.Responce), respStorage.ResStatus, nil
+					return cacheReply(respStorage), nil
+				}
+				// if cache is expired but it is still in stale-while-revalidate window
+				// immediately return it and refresh cache in background
+				if time.Now().Before(s.expiry(respStorage, p).Add(p.swr)) {
+					log.WithFields(log.Fields{
+						"id": req.ID,
+					}).Info("...and cache is stale. Revalidate it in background")
+
+					// the request to Endpoint is not needed any more
+					// revalidation is done by a separate deduplicated request
+					cancelAPI()
+					s.revalidate(req, p)
+
+					// update statistic
+					s.hits.add(req)
+
+					log.WithFields(log.Fields{
+						"id": req.ID,
+					}).Info("...returning a stale cache record for a responce")
+					return cacheReply(respStorage), nil
 				}
 			}
 		case respAPI := <-chRespAPI: // got a responce from Endpoint
+			if respAPI.Err == errCircuitOpen || respAPI.Err == ErrCannotParseMessage {
+				log.WithFields(log.Fields{
+					"id":  req.ID,
+					"err": respAPI.Err,
+				}).Warn("Endpoint did not give a usable responce...")
+				if !storageDone {
+					select {
+					case respStorage = <-chRespStorage:
+					case <-sla.C:
+					case <-ctx.Done():
+					}
+				}
+				// return any cache record even an expired one
+				if respStorage.Request != "" {
+					s.hits.add(req)
+					log.WithFields(log.Fields{
+						"id": req.ID,
+					}).Warn("...returning a cache record")
+					return cacheReply(respStorage), nil
+				}
+				log.WithFields(log.Fields{
+					"id": req.ID,
+				}).Warn("...and don't have cache")
+				if respAPI.Err == ErrCannotParseMessage {
+					return Reply{Body: []byte{}, Status: respAPI.Status}, respAPI.Err
+				}
+				return Reply{Body: []byte{}, Status: http.StatusServiceUnavailable}, ErrEndpointAPIUnavailable
+			}
+
 			log.WithFields(log.Fields{
 				"id": req.ID,
 			}).Info("Recived a responce from Endpoint")
 
-			// save a responce to cache and update statistic
-			go func() {
-				s.storage.SaveCache(Cache{
-					Request:   req.Q,
-					Responce:  string(respAPI.Resp),
-					ResStatus: respAPI.Status,
-				})
-				s.storage.UpdateStat(req)
-			}()
-
-			return respAPI.Resp, respAPI.Status, respAPI.Err
+			// a responce is already saved to cache by fetch
+			// update statistic
+			s.hits.add(req)
+
+			return Reply{Body: respAPI.Resp, Status: respAPI.Status, Header: respAPI.Header}, respAPI.Err
 		case <-sla.C: // Reached SLA
 			log.WithFields(log.Fields{
 				"id":  req.ID,
-				"sla": s.cfg.SLA,
+				"sla": p.sla,
 			}).Warn("Reached SLA...")
 			// check responce from Storage. If it not empty return it
 			// if empty then wait a responce from the endpoint
-			if respStorage != (Cache{}) && respStorage.Err == nil {
+			if respStorage.Request != "" {
 				cancelAPI()
 				log.WithFields(log.Fields{
 					"id":  req.ID,
-					"sla": s.cfg.SLA,
+					"sla": p.sla,
 				}).Warn("...returning expired cache")
-				return []byte(respStorage.Responce), respStorage.ResStatus, nil
+				return cacheReply(respStorage), nil
 			}
 			log.Warn("...and don't have cache")
+		case <-ctx.Done(): // a client has gone or the server is stopping
+			log.WithFields(log.Fields{
+				"id":  req.ID,
+				"err": ctx.Err(),
+			}).Warn("Request has been canceled")
+			return Reply{Body: []byte{}, Status: http.StatusServiceUnavailable}, ctx.Err()
 		}
 	}
 }
 
-func (s *Service) requestToAPI(req Request) APIResp {
+// Key returns a cache key of a request, see KeyBuilder
+func (s *Service) Key(u *url.URL, h http.Header) string {
+	return s.keys.Key(u, h)
+}
+
+// PathQuery returns a local path with a query of a request for the endpoint, see KeyBuilder
+func (s *Service) PathQuery(u *url.URL) string {
+	return s.keys.PathQuery(u)
+}
+
+// cacheReply returns a responce to a client from a cache record
+func cacheReply(c Cache) Reply {
+	return Reply{
+		Body:     []byte(c.Responce),
+		Status:   c.ResStatus,
+		Encoding: c.Encoding,
+		Header:   c.Header,
+	}
+}
+
+// expiry returns a time when a cache record becomes expired
+func (s *Service) expiry(c Cache, p policy) time.Time {
+	if s.cfg.HonorCacheHeaders && !c.ExpireDate.IsZero() {
+		return c.ExpireDate
+	}
+	if c.ResStatus >= 400 && c.ResStatus < 500 {
+		return c.RefreshDate.Add(s.cfg.NegativeExpiredPeriod)
+	}
+	return c.RefreshDate.Add(p.ttl)
+}
+
+// revalidate refreshes a cache record in background
+func (s *Service) revalidate(req Request, p policy) {
+	go func() {
+		res := <-s.fetch(context.Background(), req, p)
+		log.WithFields(log.Fields{
+			"id":     req.ID,
+			"status": res.resp.Status,
+			"shared": res.shared,
+		}).Info("Cache has been revalidated in background")
+	}()
+}
+
+// fetch requests the endpoint and saves a responce to cache.
+// Concurrent calls for the same query share one request to the endpoint.
+// The request to the endpoint is aborted when all callers' contexts are canceled.
+func (s *Service) fetch(ctx context.Context, req Request, p policy) <-chan flightResult {
+	return s.flight.Do(ctx, req.Q, func(ctx context.Context) APIResp {
+		// validators of a stored record for a conditional request
+		old := Cache{}
+		if s.cfg.HonorCacheHeaders {
+			var err error
+			old, err = s.storage.Cache(ctx, req.Q)
+			if err != nil {
+				if err != ErrCacheNotFound && ctx.Err() == nil {
+					s.readFailed(req, err)
+				}
+				old = Cache{}
+			}
+		}
+
+		r := s.requestToAPI(ctx, req, p, old)
+		if ctx.Err() != nil || r.Err == errCircuitOpen {
+			// the request has been aborted or has not been sent
+			// so there is nothing to save
+			return r
+		}
+
+		if r.NotModified {
+			// a stored record is still valid so only refresh it
+			log.WithFields(log.Fields{
+				"id": req.ID,
+			}).Info("Endpoint responded Not Modified. Reuse a cache record")
+			if r.ETag == "" {
+				r.ETag = old.ETag
+			}
+			if r.LastModified == "" {
+				r.LastModified = old.LastModified
+			}
+			// headers of Not Modified update stored ones
+			for k, v := range old.Header {
+				if _, ok := r.Header[k]; !ok {
+					if r.Header == nil {
+						r.Header = map[string]string{}
+					}
+					r.Header[k] = v
+				}
+			}
+			r.Resp = []byte(old.Responce)
+			r.Status = old.ResStatus
+			r.NotModified = false
+		}
+
+		s.save(ctx, req, r)
+		return r
+	})
+}
+
+// save stores a responce from the endpoint in cache according to caching policy
+func (s *Service) save(ctx context.Context, req Request, r APIResp) {
+	if r.Err != nil {
+		log.WithFields(log.Fields{
+			"id":  req.ID,
+			"err": r.Err,
+		}).Info("Did not cache a responce because of an error")
+		return
+	}
+	if s.cfg.HonorCacheHeaders && r.fresh.noStore {
+		log.WithFields(log.Fields{
+			"id": req.ID,
+		}).Info("Did not cache a responce because Endpoint forbids it")
+		return
+	}
+	if !s.cfg.Cacheable(r.Status) {
+		log.WithFields(log.Fields{
+			"id":          req.ID,
+			"status_code": r.Status,
+		}).Info("Did not cache a responce because its status code is not cacheable")
+		return
+	}
+	if r.Status >= http.StatusInternalServerError {
+		// never replace a successful record by a server error
+		c, err := s.storage.Cache(ctx, req.Q)
+		if err != nil && err != ErrCacheNotFound {
+			// it is unknown what is stored so keep it
+			s.readFailed(req, err)
+			return
+		}
+		if err == nil && c.ResStatus >= 200 && c.ResStatus < 300 {
+			log.WithFields(log.Fields{
+				"id":          req.ID,
+				"status_code": r.Status,
+			}).Info("Did not replace a successful cache record by a server error")
+			return
+		}
+	}
+	c := Cache{
+		Request:      req.Q,
+		Responce:     string(r.Resp),
+		ResStatus:    r.Status,
+		ETag:         r.ETag,
+		LastModified: r.LastModified,
+		Header:       r.Header,
+	}
+	if s.cfg.HonorCacheHeaders && r.fresh.hasMaxAge {
+		c.ExpireDate = time.Now().Add(r.fresh.maxAge)
+	}
+	if err := s.storage.SaveCache(ctx, c); err != nil && ctx.Err() == nil {
+		s.writeFailed(req, err)
+	}
+}
+
+// readFailed logs and counts a failed read from the storage
+func (s *Service) readFailed(req Request, err error) {
+	atomic.AddInt64(&s.readErrors, 1)
+	log.WithFields(log.Fields{
+		"id":  req.ID,
+		"rq":  req.Q,
+		"err": err,
+	}).Error("Cannot read a cache record from Storage")
+}
+
+// writeFailed logs and counts a failed write to the storage
+func (s *Service) writeFailed(req Request, err error) {
+	atomic.AddInt64(&s.writeErrors, 1)
+	log.WithFields(log.Fields{
+		"id":  req.ID,
+		"rq":  req.Q,
+		"err": err,
+	}).Error("Cannot write a cache record to Storage")
+}
+
+// requestToAPI sends a request to the endpoint.
+// Validators of old are used for a conditional request.
+func (s *Service) requestToAPI(ctx context.Context, req Request, p policy, old Cache) APIResp {
+	rt := p.route
+	if !p.ok {
+		log.WithFields(log.Fields{
+			"id": req.ID,
+			"rq": req.Q,
+		}).Error("There is no route to Endpoint for a request")
+		return APIResp{
+			Resp:   []byte{},
+			Status: http.StatusNotFound,
+			Err:    ErrRouteNotFound,
+		}
+	}
+	// request headers of a key are sent to the endpoint, a responce could depend on them
+	pathQuery, header := splitKeyHeader(req.Q)
+	if req.PathQuery != "" {
+		// an endpoint could tell names of parameters by case, a key is only for cache
+		pathQuery = req.PathQuery
+	}
+	url := rt.URL(pathQuery)
+	breaker := s.breakers[rt.Prefix]
+
 	log.WithFields(log.Fields{
 		"id":      req.ID,
 		"rq":      req.Q,
-		"endpoin": s.cfg.APIAddr,
+		"endpoin": url,
 	}).Info("Start processing request to Endpoint")
 
+	if !breaker.Allow() {
+		log.WithFields(log.Fields{
+			"id": req.ID,
+		}).Warn("Did not send a request to Endpoint because circuit breaker is open")
+		return APIResp{
+			Resp:   []byte{},
+			Status: http.StatusServiceUnavailable,
+			Err:    errCircuitOpen,
+		}
+	}
+
+	if header == nil {
+		header = http.Header{}
+	}
+	if old.ETag != "" {
+		header.Set("If-None-Match", old.ETag)
+	}
+	if old.LastModified != "" {
+		header.Set("If-Modified-Since", old.LastModified)
+	}
+
 	// Send a request to the endpoint
-	resp, err := http.Get(s.cfg.APIAddr + req.Q)
+	// retries of the request must fit in SLA
+	start := time.Now()
+	resp, err := s.upstream.Get(ctx, req.ID, url, header, start.Add(p.sla))
+	if ctx.Err() != nil {
+		breaker.Cancel()
+	} else {
+		breaker.Done(time.Since(start), err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	}
 	if err != nil {
+		if ctx.Err() != nil {
+			log.WithFields(log.Fields{
+				"id": req.ID,
+			}).Info("Request to endpoint has been aborted")
+			return APIResp{
+				Resp:   []byte{},
+				Status: http.StatusServiceUnavailable,
+				Err:    ctx.Err(),
+			}
+		}
 		log.WithFields(log.Fields{
 			"id":  req.ID,
 			"err": err,
-			"url": s.cfg.APIAddr + req.Q,
+			"url": url,
 		}).Error("Error while calling endpoint")
+		status := http.StatusBadGateway
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			status = http.StatusGatewayTimeout
+		}
 		return APIResp{
 			Resp:   []byte{},
-			Status: http.StatusInternalServerError,
+			Status: status,
 			Err:    ErrEndpointAPIUnavailable,
 		}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return APIResp{
+			Resp:         []byte{},
+			Status:       resp.StatusCode,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Header:       s.storedHeaders(resp.Header),
+			NotModified:  true,
+			fresh:        parseFreshness(resp.Header),
+		}
+	}
+
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -221,7 +659,7 @@ func (s *Service) requestToAPI(req Request) APIResp {
 		}).Error("Error read a response's body")
 		return APIResp{
 			Resp:   []byte{},
-			Status: http.StatusInternalServerError,
+			Status: http.StatusBadGateway,
 			Err:    ErrEndpointAPIUnavailable,
 		}
 	}
@@ -229,12 +667,22 @@ func (s *Service) requestToAPI(req Request) APIResp {
 	r := []byte{}
 	if resp.StatusCode == http.StatusOK {
 		// parse a request
-		r, err = parser.Parse(respBody)
+		var prs parser.Parser
+		prs, err = parser.Get(p.parser)
+		if err == nil {
+			r, err = prs.Parse(respBody)
+		}
 		if err != nil {
 			log.WithFields(log.Fields{
 				"id":  req.ID,
 				"err": err,
 			}).Error("Error while parsing a responce")
+			// an error is not cached so a stored record is kept and returned instead
+			return APIResp{
+				Resp:   []byte{},
+				Status: http.StatusBadGateway,
+				Err:    ErrCannotParseMessage,
+			}
 		}
 	} else {
 		log.WithFields(log.Fields{
@@ -249,29 +697,60 @@ func (s *Service) requestToAPI(req Request) APIResp {
 	}).Info("Successfully parse a request")
 
 	return APIResp{
-		Resp:   r,
-		Status: resp.StatusCode,
-		Err:    nil,
+		Resp:         r,
+		Status:       resp.StatusCode,
+		Err:          nil,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Header:       s.storedHeaders(resp.Header),
+		fresh:        parseFreshness(resp.Header),
 	}
 }
 
-// Refresh renews all cache records
-func (s *Service) Refresh() error {
-	cache, err := s.All()
-	if err != nil {
-		log.Error("Error while requested all cache records")
+// storedHeaders returns allow-listed headers of a responce of the endpoint
+func (s *Service) storedHeaders(h http.Header) map[string]string {
+	var r map[string]string
+	for _, k := range s.cfg.StoredHeaders {
+		v, ok := h[k]
+		if !ok {
+			continue
+		}
+		if r == nil {
+			r = map[string]string{}
+		}
+		r[k] = strings.Join(v, ", ")
 	}
+	return r
+}
 
-	for _, c := range cache {
-		r := s.requestToAPI(Request{
-			ID: uuid.New().String(),
-			Q:  c.Request,
-		})
-		s.storage.SaveCache(Cache{
-			Request:   c.Request,
-			Responce:  string(r.Resp),
-			ResStatus: r.Status,
-		})
+// Refresh renews all cache records
+// Records are read page by page without responces so all records are never in memory.
+// A record is refreshed under a key of current key rules, a record of an older key
+// is left to the janitor.
+func (s *Service) Refresh(ctx context.Context) error {
+	cursor := ""
+	for {
+		cache, next, err := s.storage.List(ctx, cursor, listPage, Filter{OmitBody: true})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Error while requested cache records")
+			return err
+		}
+
+		for _, c := range cache {
+			q := s.keys.Normalize(c.Request)
+			<-s.fetch(ctx, Request{
+				ID: uuid.New().String(),
+				Q:  q,
+			}, s.policy(q))
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
 	}
-	return nil
 }
@@ -1,18 +1,21 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"strings"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 )
 
 // TopN returns N most visited request from cache
-func (s *Service) TopN(n int) ([]Cache, error) {
+func (s *Service) TopN(ctx context.Context, n int) ([]Cache, error) {
 	log.WithFields(log.Fields{
 		"n": n,
 	}).Info("Top N records are requested")
-	r, err := s.storage.TopN(n)
+	r, err := s.storage.TopN(ctx, n)
 	if err != nil {
 		return []Cache{}, err
 	}
@@ -20,53 +23,174 @@ func (s *Service) TopN(n int) ([]Cache, error) {
 }
 
 // LastN returns N most unvisited request from cache
-func (s *Service) LastN(n int) ([]Cache, error) {
+func (s *Service) LastN(ctx context.Context, n int) ([]Cache, error) {
 	log.WithFields(log.Fields{
 		"n": n,
 	}).Info("Last N records are requested")
-	r, err := s.storage.LastN(n)
+	r, err := s.storage.LastN(ctx, n)
 	if err != nil {
 		return []Cache{}, err
 	}
 	return r, nil
 }
 
-// All returns all cache records
-func (s *Service) All() ([]Cache, error) {
+// listPage is a number of records read by one List when all records are needed
+const listPage = 100
+
+// All returns all cache records.
+// It keeps all records in memory, List should be used for a large cache.
+func (s *Service) All(ctx context.Context) ([]Cache, error) {
 	log.Info("All records are requested")
-	r, err := s.storage.All()
-	if err != nil {
-		return []Cache{}, err
+	r := []Cache{}
+	cursor := ""
+	for {
+		cache, next, err := s.storage.List(ctx, cursor, listPage, Filter{})
+		if err != nil {
+			return []Cache{}, err
+		}
+		r = append(r, cache...)
+		if next == "" {
+			return r, nil
+		}
+		cursor = next
 	}
-	return r, nil
+}
+
+// List returns a page of cache records which match a filter and a cursor of the next page
+func (s *Service) List(ctx context.Context, cursor string, limit int, f Filter) ([]Cache, string, error) {
+	log.WithFields(log.Fields{
+		"cursor": cursor,
+		"limit":  limit,
+	}).Info("A page of records is requested")
+	if limit <= 0 {
+		limit = listPage
+	}
+	return s.storage.List(ctx, cursor, limit, f)
 }
 
 // Settings returns all cache settings
 func (s *Service) Settings() []string {
 	log.Info("Settings are requested")
 
-	// prepare a masked DSN
-	t1 := strings.Split(s.cfg.DSN, ":")
-	t2 := strings.Split(t1[1], "@")
-	t2[0] = "**********"
-	t1[1] = strings.Join(t2, "@")
-	maskDSN := strings.Join(t1, ":")
-
 	r := []string{}
 	r = append(r, fmt.Sprintf("%v<->%v", "APIAddr", s.cfg.APIAddr))
+	r = append(r, fmt.Sprintf("%v<->%v", "Parser", s.cfg.Parser))
 	r = append(r, fmt.Sprintf("%v<->%v", "ExpiredPeriod", s.cfg.ExpiredPeriod))
+	r = append(r, fmt.Sprintf("%v<->%v", "NegativeExpiredPeriod", s.cfg.NegativeExpiredPeriod))
+	r = append(r, fmt.Sprintf("%v<->%v", "CacheableStatuses", s.cfg.CacheableStatuses))
+	r = append(r, fmt.Sprintf("%v<->%v", "HonorCacheHeaders", s.cfg.HonorCacheHeaders))
 	r = append(r, fmt.Sprintf("%v<->%v", "SLA", s.cfg.SLA))
+	r = append(r, fmt.Sprintf("%v<->%v", "StaleWhileRevalidate", s.cfg.StaleWhileRevalidate))
+	for i, route := range s.cfg.Routes {
+		r = append(r, fmt.Sprintf("%v<->%v", fmt.Sprintf("Route[%v]", i), route))
+	}
+	for i, rule := range s.cfg.Rules {
+		r = append(r, fmt.Sprintf("%v<->%v", fmt.Sprintf("Rule[%v]", i), rule))
+	}
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamDialTimeout", s.cfg.UpstreamDialTimeout))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamTLSTimeout", s.cfg.UpstreamTLSTimeout))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamResponseTimeout", s.cfg.UpstreamResponseTimeout))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamMaxIdleConnsPerHost", s.cfg.UpstreamMaxIdleConnsPerHost))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamRetries", s.cfg.UpstreamRetries))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamBackoff", s.cfg.UpstreamBackoff))
+	r = append(r, fmt.Sprintf("%v<->%v", "UpstreamMaxBackoff", s.cfg.UpstreamMaxBackoff))
+	r = append(r, fmt.Sprintf("%v<->%v", "BreakerErrorRate", s.cfg.BreakerErrorRate))
+	r = append(r, fmt.Sprintf("%v<->%v", "BreakerLatency", breakerLatency(s.cfg)))
+	r = append(r, fmt.Sprintf("%v<->%v", "BreakerWindow", s.cfg.BreakerWindow))
+	r = append(r, fmt.Sprintf("%v<->%v", "BreakerMinRequests", s.cfg.BreakerMinRequests))
+	r = append(r, fmt.Sprintf("%v<->%v", "BreakerOpenTimeout", s.cfg.BreakerOpenTimeout))
+	for _, route := range s.cfg.Routes {
+		r = append(r, fmt.Sprintf("%v<->%v", fmt.Sprintf("BreakerState[%v]", route.Prefix), s.breakers[route.Prefix]))
+	}
+	r = append(r, fmt.Sprintf("%v<->%v", "KeyDropParams", strings.Join(s.cfg.KeyDropParams, ",")))
+	r = append(r, fmt.Sprintf("%v<->%v", "KeyHeaders", strings.Join(s.cfg.KeyHeaders, ",")))
+	r = append(r, fmt.Sprintf("%v<->%v", "Storage", s.cfg.Storage))
+	r = append(r, fmt.Sprintf("%v<->%v", "Tiered", s.cfg.Tiered))
+	r = append(r, fmt.Sprintf("%v<->%v", "StatFlushInterval", s.cfg.StatFlushInterval))
+	r = append(r, fmt.Sprintf("%v<->%v", "PurgeRetention", s.cfg.PurgeRetention))
+	if s.cfg.PurgeRetention > 0 {
+		r = append(r, fmt.Sprintf("%v<->%v", "PurgeInterval", s.cfg.PurgeInterval))
+		r = append(r, fmt.Sprintf("%v<->%v", "PurgeBatch", s.cfg.PurgeBatch))
+	}
+	r = append(r, fmt.Sprintf("%v<->%v", "Compression", s.cfg.Compression))
+	if s.cfg.Storage == "inmem" || s.cfg.Tiered {
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemMaxEntries", s.cfg.InmemMaxEntries))
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemMaxBytes", s.cfg.InmemMaxBytes))
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemEviction", s.cfg.InmemEviction))
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemShards", s.cfg.InmemShards))
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemSnapshot", s.cfg.InmemSnapshot))
+		r = append(r, fmt.Sprintf("%v<->%v", "InmemSnapshotInterval", s.cfg.InmemSnapshotInterval))
+	}
+	switch s.cfg.Storage {
+	case "gorm":
+		dialect := s.cfg.Dialect
+		if dialect == "" {
+			dialect = "chosen by DSN"
+		}
+		r = append(r, fmt.Sprintf("%v<->%v", "Dialect", dialect))
+	case "redis":
+		r = append(r, fmt.Sprintf("%v<->%v", "RedisURL", maskURL(s.cfg.RedisURL)))
+		r = append(r, fmt.Sprintf("%v<->%v", "RedisKeyTTL", s.cfg.RedisKeyTTL))
+	case "bolt":
+		r = append(r, fmt.Sprintf("%v<->%v", "BoltPath", s.cfg.BoltPath))
+	}
 	r = append(r, fmt.Sprintf("%v<->%v", "HTTPAddr", s.cfg.HTTPAddr))
 	r = append(r, fmt.Sprintf("%v<->%v", "CtlAddr", s.cfg.CtlAddr))
-	r = append(r, fmt.Sprintf("%v<->%v", "DSN", maskDSN))
+	r = append(r, fmt.Sprintf("%v<->%v", "DSN", maskDSN(s.cfg.DSN)))
 	r = append(r, fmt.Sprintf("%v<->%v", "Debug", s.cfg.Debug))
 	return r
 }
 
+// Stats returns a statistic of the storage if the storage reports it
+// and numbers of failed reads and writes
+func (s *Service) Stats() []string {
+	log.Info("Storage statistic is requested")
+
+	r := []string{}
+	if sr, ok := s.storage.(StatsReporter); ok {
+		r = append(r, sr.Stats()...)
+	}
+	r = append(r, fmt.Sprintf("%v<->%v", "ReadErrors", atomic.LoadInt64(&s.readErrors)))
+	r = append(r, fmt.Sprintf("%v<->%v", "WriteErrors", atomic.LoadInt64(&s.writeErrors)))
+	if s.janitor != nil {
+		r = append(r, fmt.Sprintf("%v<->%v", "Purged", atomic.LoadInt64(&s.janitor.purged)))
+	}
+	return r
+}
+
+// maskDSN hides a password in a DSN of any SQL dialect
+func maskDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		return maskURL(dsn)
+	}
+	// user:password@tcp(host:port)/dbname
+	at := strings.Index(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + "**********" + dsn[at:]
+}
+
+// maskURL hides a password in an URL
+func maskURL(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil || u.User == nil {
+		return addr
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "**********")
+	}
+	return u.String()
+}
+
 // Clean deletes all cache records
-func (s *Service) Clean() error {
+func (s *Service) Clean(ctx context.Context) error {
 	log.Info("Deleting all records in cache")
-	err := s.storage.Clean()
+	err := s.storage.Clean(ctx)
 	if err != nil {
 		return err
 	}
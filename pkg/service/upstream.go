@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"simpleRestCache/pkg/config"
+)
+
+// upstream is an HTTP client for requests to the endpoint.
+// It retries requests on connection errors and 502/503/504 responces.
+type upstream struct {
+	client     *http.Client
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newUpstream(cfg *config.Config) *upstream {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.UpstreamDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   cfg.UpstreamTLSTimeout,
+		ResponseHeaderTimeout: cfg.UpstreamResponseTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.UpstreamMaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &upstream{
+		client:     &http.Client{Transport: transport},
+		retries:    cfg.UpstreamRetries,
+		backoff:    cfg.UpstreamBackoff,
+		maxBackoff: cfg.UpstreamMaxBackoff,
+	}
+}
+
+// Get sends a GET request with header to url.
+// Only GET requests are sent to the endpoint so all of them are safe to retry.
+// A retry is not started if it cannot finish before deadline.
+func (u *upstream) Get(ctx context.Context, id string, url string, header http.Header, deadline time.Time) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	// retries must not run past deadline
+	retryCtx, cancel := context.WithDeadline(ctx, deadline)
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx := ctx
+		if attempt > 0 {
+			attemptCtx = retryCtx
+		}
+		resp, err := u.client.Do(req.WithContext(attemptCtx))
+		if ctx.Err() != nil || !retryable(resp, err) || attempt >= u.retries {
+			return withCancel(resp, cancel), err
+		}
+
+		delay := u.delay(attempt)
+		if time.Now().Add(delay).After(deadline) {
+			log.WithFields(log.Fields{
+				"id":      id,
+				"attempt": attempt + 1,
+			}).Warn("Do not retry a request to endpoint because of SLA")
+			return withCancel(resp, cancel), err
+		}
+
+		fields := log.Fields{
+			"id":      id,
+			"attempt": attempt + 1,
+			"delay":   delay,
+		}
+		if err != nil {
+			fields["err"] = err
+		} else {
+			fields["status_code"] = resp.StatusCode
+			resp.Body.Close()
+		}
+		log.WithFields(fields).Warn("Retry a request to endpoint")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			cancel()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// delay returns an exponential backoff with jitter for an attempt
+func (u *upstream) delay(attempt int) time.Duration {
+	d := u.backoff << uint(attempt)
+	if d > u.maxBackoff || d <= 0 {
+		d = u.maxBackoff
+	}
+	// equal jitter: half of a delay is fixed, the other half is random
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryable reports whether a request should be retried
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// withCancel releases a context of a request when a body of a responce is closed
+func withCancel(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	if resp == nil {
+		cancel()
+		return nil
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
+
+// cancelBody cancels a context of a request when a body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
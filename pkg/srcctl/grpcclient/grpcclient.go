@@ -7,6 +7,7 @@ import (
 	"simpleRestCache/pb"
 	"strconv"
 	"strings"
+	"time"
 
 	timestamp "github.com/golang/protobuf/ptypes"
 	"github.com/olekukonko/tablewriter"
@@ -25,8 +26,26 @@ func New(addr string) *Handler {
 	}
 }
 
-// All returns N most visited request from cache
+// Filter selects records of List, zero fields match any record
+type Filter struct {
+	Prefix      string
+	Status      int
+	MinAge      time.Duration
+	MaxAge      time.Duration
+	MinAskCount int
+}
+
+// listPage is a number of records requested by one call of List
+const listPage = 500
+
+// All returns all requests from cache
 func (h *Handler) All() {
+	h.List(Filter{})
+}
+
+// List returns requests from cache selected by a filter.
+// Records are requested page by page without responces.
+func (h *Handler) List(f Filter) {
 	grcpConn, err := grpc.Dial(
 		h.addr,
 		grpc.WithInsecure(),
@@ -40,35 +59,56 @@ func (h *Handler) All() {
 
 	service := pb.NewSrcctlClient(grcpConn)
 
-	ctx := context.Background()
-	res, err := service.All(ctx, &pb.AllRequest{})
-	if err != nil {
-		fmt.Println("Cannot connect to the service")
-		fmt.Println("Error = ", err)
-		return
+	req := &pb.ListRequest{
+		Limit:       listPage,
+		Prefix:      f.Prefix,
+		Status:      int32(f.Status),
+		MinAskCount: int32(f.MinAskCount),
+		OmitBody:    true,
+	}
+	if f.MinAge != 0 {
+		req.MinAge = timestamp.DurationProto(f.MinAge)
+	}
+	if f.MaxAge != 0 {
+		req.MaxAge = timestamp.DurationProto(f.MaxAge)
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetBorder(false)
 	table.SetHeader([]string{"Request", "Status", "Refresh Date", "Request Date", "Count"})
 
-	for _, c := range res.Cache {
-		refDate, err := timestamp.Timestamp(c.RefreshDate)
+	ctx := context.Background()
+	for {
+		res, err := service.List(ctx, req)
 		if err != nil {
-			fmt.Println("Cannot parse a responce")
+			fmt.Println("Cannot connect to the service")
 			fmt.Println("Error = ", err)
 			return
 		}
-		reqDate, err := timestamp.Timestamp(c.RequestDate)
-		if err != nil {
-			fmt.Println("Cannot parse a responce")
-			fmt.Println("Error = ", err)
-			return
+
+		for _, c := range res.Cache {
+			refDate, err := timestamp.Timestamp(c.RefreshDate)
+			if err != nil {
+				fmt.Println("Cannot parse a responce")
+				fmt.Println("Error = ", err)
+				return
+			}
+			reqDate, err := timestamp.Timestamp(c.RequestDate)
+			if err != nil {
+				fmt.Println("Cannot parse a responce")
+				fmt.Println("Error = ", err)
+				return
+			}
+			status := strconv.FormatInt(int64(c.ResStatus), 10)
+			count := strconv.FormatInt(int64(c.AskCount), 10)
+
+			table.Append([]string{c.Request, status, refDate.Format("2006-01-02 15:04:05"), reqDate.Format("2006-01-02 15:04:05"), count})
 		}
-		status := strconv.FormatInt(int64(c.ResStatus), 10)
-		count := strconv.FormatInt(int64(c.AskCount), 10)
 
-		table.Append([]string{c.Request, status, refDate.Format("2006-01-02 15:04:05"), reqDate.Format("2006-01-02 15:04:05"), count})
+		if res.NextCursor == "" {
+			break
+		}
+		req.Cursor = res.NextCursor
 	}
 
 	table.Render()
@@ -205,6 +245,39 @@ func (h *Handler) Settings() {
 	table.Render()
 }
 
+// Stats returns a statistic of a storage
+func (h *Handler) Stats() {
+	grcpConn, err := grpc.Dial(
+		h.addr,
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		fmt.Println("Cannot connect to the service")
+		fmt.Println("Error = ", err)
+		return
+	}
+	defer grcpConn.Close()
+
+	service := pb.NewSrcctlClient(grcpConn)
+
+	ctx := context.Background()
+	res, err := service.Stats(ctx, &pb.StatsRequest{})
+	if err != nil {
+		fmt.Println("Cannot connect to the service")
+		fmt.Println("Error = ", err)
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetHeader([]string{"Name", "Value"})
+	for _, s := range res.Stats {
+		tmp := strings.Split(s, "<->")
+		table.Append([]string{tmp[0], tmp[1]})
+	}
+	table.Render()
+}
+
 // Clean deletes all cached records
 func (h *Handler) Clean() {
 	grcpConn, err := grpc.Dial(
@@ -0,0 +1,394 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bbolt "go.etcd.io/bbolt"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+)
+
+var (
+	// cacheBucket maps a request to a record
+	cacheBucket = []byte("cache")
+	// statBucket is an index of records ordered by AskCount and RequestDate.
+	// A key is a big endian AskCount, a big endian RequestDate in nanoseconds and a request.
+	statBucket = []byte("stat")
+	// dateBucket is an index of records ordered by the latest of RequestDate and RefreshDate.
+	// A key is a big endian date in nanoseconds and a request.
+	dateBucket = []byte("date")
+)
+
+// record is a cache record in a file.
+// Responce is bytes because a compressed responce is not valid UTF-8.
+type record struct {
+	Request      string            `json:"request"`
+	Responce     []byte            `json:"responce"`
+	ResStatus    int               `json:"resStatus"`
+	RefreshDate  time.Time         `json:"refreshDate"`
+	RequestDate  time.Time         `json:"requestDate"`
+	AskCount     int               `json:"askCount"`
+	ETag         string            `json:"etag"`
+	LastModified string            `json:"lastModified"`
+	ExpireDate   time.Time         `json:"expireDate"`
+	Encoding     string            `json:"encoding,omitempty"`
+	Header       map[string]string `json:"header,omitempty"`
+}
+
+// Storage stores objects in a local file with bbolt
+type Storage struct {
+	db *bbolt.DB
+}
+
+// New returns a storage object or an error if a storage file cannot be opened or prepared
+func New(cfg *config.Config) (*Storage, error) {
+	db, err := bbolt.Open(cfg.BoltPath, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open a storage file %v: %v", cfg.BoltPath, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, b := range [][]byte{cacheBucket, statBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(dateBucket) != nil {
+			return nil
+		}
+		// a file of an older version has no date index
+		return indexDates(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot prepare a storage file %v: %v", cfg.BoltPath, err)
+	}
+
+	log.WithFields(log.Fields{
+		"file": cfg.BoltPath,
+	}).Info("Storage subsystem has been initialized")
+	return &Storage{db: db}, nil
+}
+
+// Close closes a storage file
+func (s *Storage) Close() {
+	s.db.Close()
+	log.Info("Storage subsystem has been closed")
+}
+
+// statKey returns a key of a record in the stat index
+func statKey(r record) []byte {
+	k := make([]byte, 16, 16+len(r.Request))
+	binary.BigEndian.PutUint64(k[:8], uint64(r.AskCount))
+	var date int64
+	if !r.RequestDate.IsZero() {
+		date = r.RequestDate.UnixNano()
+	}
+	binary.BigEndian.PutUint64(k[8:], uint64(date))
+	return append(k, r.Request...)
+}
+
+// dateKey returns a key of a record in the date index
+func dateKey(r record) []byte {
+	date := r.RefreshDate
+	if r.RequestDate.After(date) {
+		date = r.RequestDate
+	}
+	k := make([]byte, 8, 8+len(r.Request))
+	binary.BigEndian.PutUint64(k, uint64(date.UnixNano()))
+	return append(k, r.Request...)
+}
+
+// indexDates creates the date index of all records
+func indexDates(tx *bbolt.Tx) error {
+	dates, err := tx.CreateBucket(dateBucket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(cacheBucket).ForEach(func(k, v []byte) error {
+		var r record
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		return dates.Put(dateKey(r), nil)
+	})
+}
+
+// get reads a record of a request, ok is false if there is no record
+func get(tx *bbolt.Tx, req string) (r record, ok bool, err error) {
+	v := tx.Bucket(cacheBucket).Get([]byte(req))
+	if v == nil {
+		return record{}, false, nil
+	}
+	if err := json.Unmarshal(v, &r); err != nil {
+		return record{}, false, err
+	}
+	return r, true, nil
+}
+
+// put writes a record and its indexes, old is a previous version of the record if any
+func put(tx *bbolt.Tx, r record, old *record) error {
+	if old != nil {
+		if err := tx.Bucket(statBucket).Delete(statKey(*old)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(dateBucket).Delete(dateKey(*old)); err != nil {
+			return err
+		}
+	}
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(cacheBucket).Put([]byte(r.Request), v); err != nil {
+		return err
+	}
+	if err := tx.Bucket(statBucket).Put(statKey(r), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(dateBucket).Put(dateKey(r), nil)
+}
+
+// Cache returns cache for a requested string
+func (s *Storage) Cache(ctx context.Context, req string) (service.Cache, error) {
+	if err := ctx.Err(); err != nil {
+		return service.Cache{}, err
+	}
+	var (
+		r  record
+		ok bool
+	)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		r, ok, err = get(tx, req)
+		return err
+	})
+	if err != nil {
+		return service.Cache{}, err
+	}
+	if !ok {
+		return service.Cache{}, service.ErrCacheNotFound
+	}
+	return toCache(r), nil
+}
+
+// SaveCache saves record to cache
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		r := record{
+			Request:      c.Request,
+			Responce:     []byte(c.Responce),
+			ResStatus:    c.ResStatus,
+			RefreshDate:  time.Now(),
+			ETag:         c.ETag,
+			LastModified: c.LastModified,
+			ExpireDate:   c.ExpireDate,
+			Encoding:     c.Encoding,
+			Header:       c.Header,
+		}
+		old, ok, err := get(tx, c.Request)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return put(tx, r, nil)
+		}
+		// if a record is exist save RequestDate and AskCount
+		r.RequestDate = old.RequestDate
+		r.AskCount = old.AskCount
+		return put(tx, r, &old)
+	})
+}
+
+// UpdateStat updates statistic of a partitional cache record
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		old, ok, err := get(tx, st.Request)
+		if err != nil || !ok {
+			// a record was not saved at all
+			return err
+		}
+		r := old
+		r.AskCount += st.Count
+		if st.RequestDate.After(r.RequestDate) {
+			r.RequestDate = st.RequestDate
+		}
+		return put(tx, r, &old)
+	})
+}
+
+// TopN returns N most visited requests from cache
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.rangeN(ctx, n, true)
+}
+
+// LastN returns N most unvisited requests from cache
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.rangeN(ctx, n, false)
+}
+
+// rangeN reads N records in order of the stat index
+func (s *Storage) rangeN(ctx context.Context, n int, desc bool) ([]service.Cache, error) {
+	result := []service.Cache{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(statBucket).Cursor()
+		first, next := c.First, c.Next
+		if desc {
+			first, next = c.Last, c.Prev
+		}
+		for k, _ := first(); k != nil && len(result) < n; k, _ = next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			r, ok, err := get(tx, string(k[16:]))
+			if err != nil {
+				return err
+			}
+			if ok {
+				result = append(result, toCache(r))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []service.Cache{}, err
+	}
+	return result, nil
+}
+
+// List returns a page of records in order of requests, a cursor is the last listed request.
+// A prefix of requests is sought in the file so other records are not read.
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	result := []service.Cache{}
+	next := ""
+	now := time.Now()
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(cacheBucket).Cursor()
+		start := []byte(f.Prefix)
+		if cursor > f.Prefix {
+			start = []byte(cursor)
+		}
+		k, v := c.Seek(start)
+		if k != nil && string(k) == cursor {
+			k, v = c.Next()
+		}
+		for ; k != nil && bytes.HasPrefix(k, []byte(f.Prefix)); k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if len(result) == limit {
+				// following records could match, the next page starts after the last listed one
+				next = result[len(result)-1].Request
+				return nil
+			}
+			r := record{}
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			sc := toCache(r)
+			if !f.Match(sc, now) {
+				continue
+			}
+			if f.OmitBody {
+				sc.Responce = ""
+			}
+			result = append(result, sc)
+		}
+		return nil
+	})
+	if err != nil {
+		return []service.Cache{}, "", err
+	}
+	return result, next, nil
+}
+
+// Clean deletes all cache records
+func (s *Storage) Clean(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, b := range [][]byte{cacheBucket, statBucket, dateBucket} {
+			if err := tx.DeleteBucket(b); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Purge deletes at most limit records which were neither requested nor refreshed since before.
+// The oldest records are read from the date index, so limit bounds a time of a write lock of the file.
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	n := 0
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		// records are collected first because a cursor skips records deleted on the way
+		dead := []record{}
+		c := tx.Bucket(dateBucket).Cursor()
+		for k, _ := c.First(); k != nil && len(dead) < limit; k, _ = c.Next() {
+			if int64(binary.BigEndian.Uint64(k[:8])) >= before.UnixNano() {
+				break
+			}
+			r, ok, err := get(tx, string(k[8:]))
+			if err != nil {
+				return err
+			}
+			if ok {
+				dead = append(dead, r)
+			}
+		}
+		for _, r := range dead {
+			if err := tx.Bucket(cacheBucket).Delete([]byte(r.Request)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(statBucket).Delete(statKey(r)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(dateBucket).Delete(dateKey(r)); err != nil {
+				return err
+			}
+		}
+		n = len(dead)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// toCache converts a record to service.Cache
+func toCache(r record) service.Cache {
+	return service.Cache{
+		Request:      r.Request,
+		Responce:     string(r.Responce),
+		ResStatus:    r.ResStatus,
+		RefreshDate:  r.RefreshDate,
+		RequestDate:  r.RequestDate,
+		AskCount:     r.AskCount,
+		ETag:         r.ETag,
+		LastModified: r.LastModified,
+		ExpireDate:   r.ExpireDate,
+		Encoding:     r.Encoding,
+		Header:       r.Header,
+	}
+}
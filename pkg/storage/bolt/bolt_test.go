@@ -0,0 +1,174 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+// newTestStorage returns a storage in a temporary file and a func which checks
+// the stat index, closes the storage and removes the file
+func newTestStorage(t *testing.T) (*Storage, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "srcbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(&config.Config{BoltPath: filepath.Join(dir, "cache.db")})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		checkIndexes(t, s)
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// checkIndexes checks that every record has one key in the stat index and one key
+// in the date index of its current version and that indexes have no other keys
+func checkIndexes(t *testing.T, s *Storage) {
+	t.Helper()
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		records := 0
+		err := tx.Bucket(cacheBucket).ForEach(func(k, v []byte) error {
+			records++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, index := range []struct {
+			bucket []byte
+			key    func(record) []byte
+			prefix int
+		}{
+			{statBucket, statKey, 16},
+			{dateBucket, dateKey, 8},
+		} {
+			keys := 0
+			err := tx.Bucket(index.bucket).ForEach(func(k, v []byte) error {
+				keys++
+				r, ok, err := get(tx, string(k[index.prefix:]))
+				if err != nil {
+					return err
+				}
+				if !ok || !bytes.Equal(index.key(r), k) {
+					t.Errorf("a stale key of %q in the %s index", k[index.prefix:], index.bucket)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if keys != records {
+				t.Errorf("%d keys in the %s index of %d records", keys, index.bucket, records)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		return newTestStorage(t)
+	})
+}
+
+func TestNewError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := New(&config.Config{BoltPath: filepath.Join(dir, "missing", "cache.db")}); err == nil {
+		t.Error("a file in a missing directory is opened")
+	}
+}
+
+func TestIndexesReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{BoltPath: filepath.Join(dir, "cache.db")}
+	ctx := context.Background()
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagetest.Save(t, s, "/a", "/b")
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/b", Count: 2, RequestDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// records and their index are kept in the file
+	s, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	checkIndexes(t, s)
+	top, err := s.TopN(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := storagetest.Requests(top); !storagetest.Equal(got, []string{"/b", "/a"}) {
+		t.Errorf("TopN after reopen %v", got)
+	}
+}
+
+func TestIndexDates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{BoltPath: filepath.Join(dir, "cache.db")}
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagetest.Save(t, s, "/a", "/b")
+	// a file of an older version has no date index
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.DeleteBucket(dateBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	checkIndexes(t, s)
+	n, err := s.Purge(context.Background(), time.Now().Add(time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Purge deleted %d, want 2", n)
+	}
+}
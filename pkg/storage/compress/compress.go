@@ -0,0 +1,206 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+
+	service "simpleRestCache/pkg/service"
+)
+
+// Encodings of compressed responces, they are the same as HTTP content codings
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	// zstd encoder and decoder are safe for concurrent use with EncodeAll and DecodeAll
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Backend is a storage which records are compressed
+type Backend interface {
+	service.Storage
+	Close()
+}
+
+// Storage compresses responces of records on write and decompresses them on read.
+// A record is returned compressed as is if a client accepts its encoding, see service.WithAcceptEncoding.
+// Records are decompressed according to their encoding so the algorithm can be changed
+// while old records are still in the storage.
+type Storage struct {
+	next     Backend
+	encoding string
+
+	rawBytes        int64
+	compressedBytes int64
+}
+
+// New returns a storage which compresses records of next with an encoding.
+// An empty encoding means that new records are not compressed.
+func New(next Backend, encoding string) *Storage {
+	if encoding == "none" {
+		encoding = ""
+	}
+	log.WithFields(log.Fields{
+		"compression": encoding,
+	}).Info("Compression of stored responses has been initialized")
+	return &Storage{
+		next:     next,
+		encoding: encoding,
+	}
+}
+
+// Close closes a wrapped storage
+func (s *Storage) Close() {
+	s.next.Close()
+}
+
+// Cache returns cache for a requested string
+func (s *Storage) Cache(ctx context.Context, r string) (service.Cache, error) {
+	c, err := s.next.Cache(ctx, r)
+	if err != nil {
+		return service.Cache{}, err
+	}
+	if c.Encoding == "" || service.Accepts(ctx, c.Encoding) {
+		return c, nil
+	}
+	return decompress(c)
+}
+
+// SaveCache compresses a responce and saves record to cache.
+// A responce is stored uncompressed if compression does not make it smaller.
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
+	if s.encoding != "" && c.Encoding == "" {
+		b, err := encode(s.encoding, []byte(c.Responce))
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.rawBytes, int64(len(c.Responce)))
+		if len(b) < len(c.Responce) {
+			c.Responce = string(b)
+			c.Encoding = s.encoding
+		}
+		atomic.AddInt64(&s.compressedBytes, int64(len(c.Responce)))
+	}
+	return s.next.SaveCache(ctx, c)
+}
+
+// UpdateStat updates statistic of a partitional cache record
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
+	return s.next.UpdateStat(ctx, st)
+}
+
+// TopN returns N most visited requests from cache
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	return decompressAll(s.next.TopN(ctx, n))
+}
+
+// LastN returns N most unvisited requests from cache
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	return decompressAll(s.next.LastN(ctx, n))
+}
+
+// List returns a page of records, responces are decompressed unless they are omitted
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	cache, next, err := s.next.List(ctx, cursor, limit, f)
+	if err != nil || f.OmitBody {
+		return cache, next, err
+	}
+	cache, err = decompressAll(cache, nil)
+	return cache, next, err
+}
+
+// Clean deletes all cache records
+func (s *Storage) Clean(ctx context.Context) error {
+	return s.next.Clean(ctx)
+}
+
+// Purge deletes dead records
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	return s.next.Purge(ctx, before, limit)
+}
+
+// Stats returns sizes of written responces and statistic of a wrapped storage if it reports it
+func (s *Storage) Stats() []string {
+	r := []string{}
+	if sr, ok := s.next.(service.StatsReporter); ok {
+		r = append(r, sr.Stats()...)
+	}
+	if s.encoding == "" {
+		return append(r, fmt.Sprintf("%v<->%v", "Compression", "none"))
+	}
+	r = append(r, fmt.Sprintf("%v<->%v", "Compression", s.encoding))
+	r = append(r, fmt.Sprintf("%v<->%v", "RawBytes", atomic.LoadInt64(&s.rawBytes)))
+	r = append(r, fmt.Sprintf("%v<->%v", "CompressedBytes", atomic.LoadInt64(&s.compressedBytes)))
+	return r
+}
+
+// decompressAll decompresses responces of records
+func decompressAll(cache []service.Cache, err error) ([]service.Cache, error) {
+	if err != nil {
+		return []service.Cache{}, err
+	}
+	for i, c := range cache {
+		if c.Encoding == "" {
+			continue
+		}
+		if cache[i], err = decompress(c); err != nil {
+			return []service.Cache{}, err
+		}
+	}
+	return cache, nil
+}
+
+// decompress returns a record with an uncompressed responce
+func decompress(c service.Cache) (service.Cache, error) {
+	b, err := decode(c.Encoding, []byte(c.Responce))
+	if err != nil {
+		return service.Cache{}, fmt.Errorf("cannot decompress a record of %q: %v", c.Request, err)
+	}
+	c.Responce = string(b)
+	c.Encoding = ""
+	return c, nil
+}
+
+func encode(encoding string, b []byte) ([]byte, error) {
+	switch encoding {
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(b, nil), nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
+
+func decode(encoding string, b []byte) ([]byte, error) {
+	switch encoding {
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case Zstd:
+		return zstdDecoder.DecodeAll(b, nil)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
@@ -2,64 +2,128 @@ package inmem
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
+	// supported SQL dialects
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	log "github.com/sirupsen/logrus"
 
 	"simpleRestCache/pkg/config"
 	service "simpleRestCache/pkg/service"
 )
 
-// Cache represents cache in a database
+// Cache represents cache in a database.
+// A record is keyed by a hash of a request because a request can be longer
+// then an index allows. The table is created by migrations, see migrate.go.
+// Sizes of columns are hints for every dialect:
+// a size less then 65532 is varchar, a larger one is longtext in MySQL and text in others.
+// Responce is bytes, it is longblob in MySQL, bytea in Postgres and blob in SQLite,
+// because a compressed responce is not valid text.
 type Cache struct {
-	Request     string `gorm:"primary_key"`
-	Responce    string `gorm:"type:text"`
-	ResStatus   int
-	RefreshDate time.Time
-	RequestDate time.Time
-	AskCount    int
-	Err         error `gorm:"-"`
+	KeyHash      string `gorm:"primary_key;size:64"`
+	Request      string `gorm:"size:65536"`
+	Responce     []byte `gorm:"size:16777215"`
+	ResStatus    int
+	RefreshDate  time.Time
+	RequestDate  time.Time
+	AskCount     int
+	ETag         string `gorm:"size:255"`
+	LastModified string `gorm:"size:64"`
+	ExpireDate   time.Time
+	Encoding     string `gorm:"size:16"`
+	Header       string `gorm:"size:4096"` // JSON of stored headers of a responce
+}
+
+// TableName returns a table of cache records
+func (Cache) TableName() string {
+	return cacheTable
+}
+
+// hashKey returns a key of a record of a request, it is a hex of SHA-256
+func hashKey(r string) string {
+	h := sha256.Sum256([]byte(r))
+	return hex.EncodeToString(h[:])
 }
 
 // Storage stores objects in memory
 type Storage struct {
-	db     *gorm.DB
-	dsn    string
-	cancel context.CancelFunc
+	db      *gorm.DB
+	dialect string
+	dsn     string
+	cancel  context.CancelFunc
 }
 
 // New returns a storage object
 func New(cfg *config.Config) *Storage {
 	ctx, cancel := context.WithCancel(context.Background())
+	dialect, dsn := Dialect(cfg.Dialect, cfg.DSN)
 	s := &Storage{
-		db:     nil,
-		dsn:    cfg.DSN,
-		cancel: cancel,
+		db:      nil,
+		dialect: dialect,
+		dsn:     dsn,
+		cancel:  cancel,
 	}
 
 	go s.connectToDB(ctx)
 
-	log.Info("Storage subsystem has been initialized")
+	log.WithFields(log.Fields{
+		"dialect": dialect,
+	}).Info("Storage subsystem has been initialized")
 	return s
 }
 
+// Dialect returns a SQL dialect and a DSN for a driver of the dialect.
+// If a dialect is not set it is chosen by a scheme of the DSN:
+// "postgres://" and "postgresql://" mean postgres, "sqlite3://" means sqlite3
+// with a file path after the scheme, otherwise it is mysql.
+func Dialect(dialect, dsn string) (string, string) {
+	scheme := ""
+	if i := strings.Index(dsn, "://"); i > 0 {
+		scheme = dsn[:i]
+	}
+	if dialect == "" {
+		switch scheme {
+		case "postgres", "postgresql":
+			dialect = "postgres"
+		case "sqlite3", "sqlite":
+			dialect = "sqlite3"
+		default:
+			dialect = "mysql"
+		}
+	}
+	if dialect == "sqlite3" && scheme != "" {
+		// go-sqlite3 expects a file name
+		dsn = strings.TrimPrefix(dsn, scheme+"://")
+	}
+	return dialect, dsn
+}
+
 func (s *Storage) connectToDB(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	for {
 		select {
 		case <-ticker.C:
 			if s.db == nil {
-				db, err := gorm.Open("mysql", s.dsn)
+				db, err := gorm.Open(s.dialect, s.dsn)
 				if err != nil {
 					log.WithFields(log.Fields{
 						"err": err,
 					}).Error("Error while connected to a database")
+				} else if err := migrate(db); err != nil {
+					// the schema is unknown so the database is not used
+					log.WithFields(log.Fields{
+						"err": err,
+					}).Error("Error while migrated a database schema")
+					db.Close()
 				} else {
 					log.Info("Connection to a database is established")
 					s.db = db
-					s.db.AutoMigrate(&Cache{})
 				}
 
 			}
@@ -73,7 +137,10 @@ func (s *Storage) connectToDB(ctx context.Context) {
 			}
 		case <-ctx.Done():
 			ticker.Stop()
-			s.db.Close()
+			if s.db != nil {
+				s.db.Close()
+			}
+			return
 		}
 	}
 }
@@ -84,152 +151,204 @@ func (s *Storage) Close() {
 	log.Info("Storage subsystem has been closed")
 }
 
+// conn returns a connection to a database.
+// gorm does not support contexts so ctx is only checked before a query.
+func (s *Storage) conn(ctx context.Context) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.db == nil {
+		return nil, service.ErrStorageUnavailable
+	}
+	return s.db, nil
+}
+
 // Cache returns cache for a requested string
-func (s *Storage) Cache(r string) service.Cache {
+func (s *Storage) Cache(ctx context.Context, r string) (service.Cache, error) {
+	db, err := s.conn(ctx)
+	if err != nil {
+		return service.Cache{}, err
+	}
 	c := Cache{}
-	if s.db != nil {
-		var count int
-		s.db.Where("Request = ?", r).First(&c).Count(&count)
-		if count == 0 {
-			return service.Cache{Err: service.ErrCacheNotFound}
-		}
-		// convert datatypes from different packages
-		// gorm.Cache -> service.Cache
-		res := service.Cache{
-			Request:     c.Request,
-			Responce:    c.Responce,
-			ResStatus:   c.ResStatus,
-			RefreshDate: c.RefreshDate,
-			RequestDate: c.RequestDate,
-			AskCount:    c.AskCount,
-		}
-		return res
+	err = db.Where("key_hash = ?", hashKey(r)).First(&c).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return service.Cache{}, service.ErrCacheNotFound
+	}
+	if err != nil {
+		return service.Cache{}, err
 	}
-	return service.Cache{Err: service.ErrStorageUnavailable}
+	return toCache(c), nil
 }
 
 // SaveCache saves record to cache
-func (s *Storage) SaveCache(c service.Cache) {
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
+	db, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
 	// convert datatypes from different packages
 	// service.Cache -> gorm.Cache
 	lc := Cache{
-		Request:     c.Request,
-		Responce:    c.Responce,
-		ResStatus:   c.ResStatus,
-		RefreshDate: time.Now(),
+		KeyHash:      hashKey(c.Request),
+		Request:      c.Request,
+		Responce:     []byte(c.Responce),
+		ResStatus:    c.ResStatus,
+		RefreshDate:  time.Now(),
+		ETag:         c.ETag,
+		LastModified: c.LastModified,
+		ExpireDate:   c.ExpireDate,
+		Encoding:     c.Encoding,
+		Header:       service.EncodeHeader(c.Header),
 	}
 	tmp := Cache{}
-	if s.db != nil {
-		var count int
-		s.db.Where("Request = ?", c.Request).First(&tmp).Count(&count)
-		if count != 0 {
-			lc.RequestDate = tmp.RequestDate
-			lc.AskCount = tmp.AskCount
-		}
-		s.db.Save(&lc)
+	err = db.Where("key_hash = ?", lc.KeyHash).First(&tmp).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
 	}
+	if err == nil {
+		lc.RequestDate = tmp.RequestDate
+		lc.AskCount = tmp.AskCount
+	}
+	return db.Save(&lc).Error
 }
 
 // UpdateStat updates statistic of a partitional cache record
-func (s *Storage) UpdateStat(req service.Request) {
-	if s.db != nil {
-		c := Cache{}
-		var count int
-		s.db.Where("Request = ?", req.Q).First(&c).Count(&count)
-		if count != 1 {
-			log.WithFields(log.Fields{
-				"req": req.Q,
-			}).Error("Two cache records for the same request")
-			return
-		}
-		ac := c.AskCount + 1
-		c.AskCount = ac
-		c.RequestDate = time.Now()
-
-		s.db.Save(&c)
-
-		log.WithFields(log.Fields{
-			"id":    req.ID,
-			"query": req.Q,
-			"count": ac,
-		}).Info("Statistics has been updated ")
+// It is one atomic statement which does not touch other columns.
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
+	db, err := s.conn(ctx)
+	if err != nil {
+		return err
 	}
+	// a record could be not saved at all, then nothing is updated
+	return db.Model(&Cache{}).Where("key_hash = ?", hashKey(st.Request)).UpdateColumns(map[string]interface{}{
+		"ask_count":    gorm.Expr("ask_count + ?", st.Count),
+		"request_date": st.RequestDate,
+	}).Error
 }
 
-// All returns all requests from cache
-func (s *Storage) All() ([]service.Cache, error) {
-	cache := []Cache{}
-	result := []service.Cache{}
-	if s.db != nil {
-		s.db.Find(&cache)
-		for _, c := range cache {
-			// convert datatypes from different packages
-			// gorm.Cache -> service.Cache
-			result = append(result, service.Cache{
-				Request:     c.Request,
-				Responce:    c.Responce,
-				ResStatus:   c.ResStatus,
-				RefreshDate: c.RefreshDate,
-				RequestDate: c.RequestDate,
-				AskCount:    c.AskCount,
-			})
+// listColumns are columns of a listing without responces
+const listColumns = "key_hash, request, res_status, refresh_date, request_date, ask_count, " +
+	"e_tag, last_modified, expire_date, encoding, header"
+
+// List returns a page of records in order of key hashes, a cursor is the last listed key hash.
+// Filters are conditions of one query, one more record is read to know if there is the next page.
+// A prefix is matched by the index of requests, but records of a prefix are sorted by key hashes,
+// so a page of a prefix costs a number of its records and not a number of all records.
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	now := time.Now()
+	cache, err := s.find(ctx, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("key_hash > ?", cursor)
+		if f.Prefix != "" {
+			db = db.Where("request LIKE ? ESCAPE '!'", likePrefix(f.Prefix))
+		}
+		if f.Status != 0 {
+			db = db.Where("res_status = ?", f.Status)
+		}
+		if f.MinAge != 0 {
+			db = db.Where("refresh_date <= ?", now.Add(-f.MinAge))
+		}
+		if f.MaxAge != 0 {
+			db = db.Where("refresh_date >= ?", now.Add(-f.MaxAge))
+		}
+		if f.MinAskCount != 0 {
+			db = db.Where("ask_count >= ?", f.MinAskCount)
+		}
+		if f.OmitBody {
+			db = db.Select(listColumns)
 		}
-		return result, nil
+		return db.Order("key_hash").Limit(limit + 1)
+	})
+	if err != nil {
+		return cache, "", err
 	}
-	return result, service.ErrStorageUnavailable
+	if len(cache) <= limit {
+		return cache, "", nil
+	}
+	cache = cache[:limit]
+	return cache, hashKey(cache[limit-1].Request), nil
+}
+
+// likePrefix returns a LIKE pattern of a prefix with '!' as an escape character
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return r.Replace(prefix) + "%"
 }
 
 // Clean deletes all cache records
-func (s *Storage) Clean() error {
-	if s.db != nil {
-		s.db.Where("responce LIKE ?", "%").Delete(Cache{})
-		return nil
+func (s *Storage) Clean(ctx context.Context) error {
+	db, err := s.conn(ctx)
+	if err != nil {
+		return err
 	}
-	return service.ErrStorageUnavailable
+	return db.Delete(Cache{}).Error
 }
 
-// TopN returns N most visited requests from cache
-func (s *Storage) TopN(n int) ([]service.Cache, error) {
-	cache := []Cache{}
-	result := []service.Cache{}
-	if s.db != nil {
-		s.db.Order("ask_count desc, request_date desc").Limit(n).Find(&cache)
-		for _, c := range cache {
-			// convert datatypes from different packages
-			// gorm.Cache -> service.Cache
-			result = append(result, service.Cache{
-				Request:     c.Request,
-				Responce:    c.Responce,
-				ResStatus:   c.ResStatus,
-				RefreshDate: c.RefreshDate,
-				RequestDate: c.RequestDate,
-				AskCount:    c.AskCount,
-			})
-		}
-		return result, nil
+// Purge deletes at most limit records which were neither requested nor refreshed since before.
+// Keys are selected first because not every dialect supports DELETE with LIMIT,
+// so one statement locks at most limit rows.
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	db, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
 	}
-	return result, service.ErrStorageUnavailable
+	keys := []string{}
+	err = db.Model(&Cache{}).Where("request_date < ? AND refresh_date < ?", before, before).
+		Limit(limit).Pluck("key_hash", &keys).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	res := db.Where("key_hash IN (?)", keys).Delete(Cache{})
+	return int(res.RowsAffected), res.Error
+}
+
+// TopN returns N most visited requests from cache
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.find(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Order("ask_count desc, request_date desc").Limit(n)
+	})
 }
 
 // LastN returns N most unvisited requests from cache
-func (s *Storage) LastN(n int) ([]service.Cache, error) {
-	cache := []Cache{}
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.find(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Order("ask_count, request_date").Limit(n)
+	})
+}
+
+// find returns records selected by a query
+func (s *Storage) find(ctx context.Context, query func(db *gorm.DB) *gorm.DB) ([]service.Cache, error) {
 	result := []service.Cache{}
-	if s.db != nil {
-		s.db.Order("ask_count, request_date").Limit(n).Find(&cache)
-		for _, c := range cache {
-			// convert datatypes from different packages
-			// gorm.Cache -> service.Cache
-			result = append(result, service.Cache{
-				Request:     c.Request,
-				Responce:    c.Responce,
-				ResStatus:   c.ResStatus,
-				RefreshDate: c.RefreshDate,
-				RequestDate: c.RequestDate,
-				AskCount:    c.AskCount,
-			})
-		}
-		return result, nil
+	db, err := s.conn(ctx)
+	if err != nil {
+		return result, err
+	}
+	cache := []Cache{}
+	if err := query(db).Find(&cache).Error; err != nil {
+		return result, err
+	}
+	for _, c := range cache {
+		result = append(result, toCache(c))
+	}
+	return result, nil
+}
+
+// toCache converts datatypes from different packages
+// gorm.Cache -> service.Cache
+func toCache(c Cache) service.Cache {
+	return service.Cache{
+		Request:      c.Request,
+		Responce:     string(c.Responce),
+		ResStatus:    c.ResStatus,
+		RefreshDate:  c.RefreshDate,
+		RequestDate:  c.RequestDate,
+		AskCount:     c.AskCount,
+		ETag:         c.ETag,
+		LastModified: c.LastModified,
+		ExpireDate:   c.ExpireDate,
+		Encoding:     c.Encoding,
+		Header:       service.DecodeHeader(c.Header),
 	}
-	return result, service.ErrStorageUnavailable
 }
@@ -0,0 +1,179 @@
+package inmem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+// openTestDB opens a SQLite database in a temporary file and a func which removes it.
+// A test is skipped if SQLite is not available, go-sqlite3 requires cgo.
+func openTestDB(t *testing.T) (*gorm.DB, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "srcgorm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialect, dsn := Dialect("", "sqlite3://"+filepath.Join(dir, "cache.db"))
+	db, err := gorm.Open(dialect, dsn)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("SQLite is not available: %v", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newTestStorage returns a storage on a migrated SQLite database
+func newTestStorage(t *testing.T) (*Storage, func()) {
+	t.Helper()
+	db, closeDB := openTestDB(t)
+	if err := migrate(db); err != nil {
+		closeDB()
+		t.Fatal(err)
+	}
+	return &Storage{db: db, dialect: "sqlite3"}, closeDB
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		return newTestStorage(t)
+	})
+}
+
+func TestDialect(t *testing.T) {
+	for _, tc := range []struct {
+		dialect, dsn   string
+		wantD, wantDSN string
+	}{
+		{"", "user:pass@tcp(db:3306)/cache", "mysql", "user:pass@tcp(db:3306)/cache"},
+		{"", "postgres://user@db/cache", "postgres", "postgres://user@db/cache"},
+		{"", "postgresql://user@db/cache", "postgres", "postgresql://user@db/cache"},
+		{"", "sqlite3:///var/cache.db", "sqlite3", "/var/cache.db"},
+		{"sqlite3", "cache.db", "sqlite3", "cache.db"},
+	} {
+		d, dsn := Dialect(tc.dialect, tc.dsn)
+		if d != tc.wantD || dsn != tc.wantDSN {
+			t.Errorf("Dialect(%q, %q) = %q, %q, want %q, %q", tc.dialect, tc.dsn, d, dsn, tc.wantD, tc.wantDSN)
+		}
+	}
+}
+
+// createLegacy creates a cache table of older versions which is keyed by a request
+// and has no newer columns, requests get AskCount by their order
+func createLegacy(t *testing.T, db *gorm.DB, table string, date time.Time, reqs ...string) {
+	t.Helper()
+	err := db.Exec(`CREATE TABLE ` + table + ` (request varchar(255) PRIMARY KEY, responce text, res_status integer,
+		refresh_date datetime, request_date datetime, ask_count integer)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range reqs {
+		err := db.Exec("INSERT INTO "+table+" (request, responce, res_status, refresh_date, request_date, ask_count) VALUES (?, ?, ?, ?, ?, ?)",
+			r, "body", 200, date, date.Add(time.Duration(i)*time.Second), i).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, stop := openTestDB(t)
+	defer stop()
+	ctx := context.Background()
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	reqs := []string{}
+	for i := 0; i < 2*copyBatch+3; i++ {
+		// keys of the first versions are a query only
+		reqs = append(reqs, "?term="+strings.Repeat("m", i+1))
+	}
+	reqs = append(reqs, "?Term=a&utm_source=x", "/other/x?B=2&a=1")
+	createLegacy(t, db, cacheTable, date, reqs...)
+
+	for i := 0; i < 2; i++ {
+		// the second run applies nothing
+		if err := migrate(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied := []schemaMigration{}
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations()) {
+		t.Errorf("applied migrations %+v", applied)
+	}
+	if !db.HasTable(legacyTable) {
+		t.Errorf("legacy table is deleted")
+	}
+	if !db.Dialect().HasIndex(cacheTable, requestIndex) {
+		t.Errorf("requests are not indexed")
+	}
+
+	s := &Storage{db: db, dialect: "sqlite3"}
+	count := 0
+	if err := db.Model(&Cache{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != len(reqs) {
+		t.Errorf("copied %d records, want %d", count, len(reqs))
+	}
+	// records are copied as they are
+	for i, r := range []string{"?term=mmm", "?Term=a&utm_source=x", "/other/x?B=2&a=1"} {
+		c, err := s.Cache(ctx, r)
+		if err != nil {
+			t.Fatalf("record %q: %v", r, err)
+		}
+		if i == 0 && (c.Responce != "body" || c.ResStatus != 200 || c.AskCount != 2 || !c.RequestDate.Equal(date.Add(2*time.Second))) {
+			t.Errorf("copied record %+v", c)
+		}
+	}
+}
+
+func TestMigrateRetry(t *testing.T) {
+	db, stop := openTestDB(t)
+	defer stop()
+	ctx := context.Background()
+
+	// MySQL commits DDL at once and batches are committed one by one, so a crashed run
+	// could leave renamed and created tables with a part of copied records without applied versions
+	createLegacy(t, db, legacyTable, time.Now(), "?term=a", "?term=b")
+	if err := db.CreateTable(&Cache{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	s := &Storage{db: db, dialect: "sqlite3"}
+	if err := s.SaveCache(ctx, service.Cache{Request: "?term=a", Responce: "copied", ResStatus: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, _, err := s.List(ctx, "", 100, service.Filter{Prefix: "?term="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 2 {
+		t.Errorf("copied records %v", storagetest.Requests(cache))
+	}
+	c, err := s.Cache(ctx, "?term=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Responce != "copied" {
+		t.Errorf("a copied record is copied again: %+v", c)
+	}
+}
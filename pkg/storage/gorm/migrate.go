@@ -0,0 +1,261 @@
+package inmem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// cacheTable is a table of cache records
+	cacheTable = "caches"
+	// legacyTable is a table of cache records keyed by a request, it is kept after an upgrade
+	legacyTable = "caches_legacy"
+	// copyBatch is a number of legacy records copied by one transaction
+	copyBatch = 500
+)
+
+// schemaMigration is an applied migration of a database schema
+type schemaMigration struct {
+	Version   int `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName returns a table of applied migrations
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migration changes a database schema, it is applied once in a transaction.
+// A batched migration copies data and commits a transaction per batch itself.
+// DDL is not transactional in MySQL and batches are committed one by one,
+// so a step checks what is already done and a retry after a crash continues it.
+// A migration depends only on a database, never on settings, so it gives the same result on every run.
+type migration struct {
+	version int
+	name    string
+	up      func(db *gorm.DB) error
+	batched bool
+}
+
+// migrations returns migrations in order of versions. Applied migrations must not be changed,
+// a new change of the schema is a new migration.
+func migrations() []migration {
+	return []migration{
+		{1, "rename a legacy cache table", renameLegacy, false},
+		{2, "create a cache table with hashed keys and binary responces", createCache, false},
+		{3, "copy legacy cache records", copyLegacy, true},
+		{4, "index requests of cache records", indexRequest, false},
+	}
+}
+
+// migrate applies migrations which are not applied yet
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return err
+	}
+	applied := []schemaMigration{}
+	if err := db.Find(&applied).Error; err != nil {
+		return err
+	}
+	done := map[int]bool{}
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations() {
+		if done[m.version] {
+			continue
+		}
+		if m.batched {
+			// a batched migration commits its batches itself
+			if err := m.up(db); err != nil {
+				return fmt.Errorf("migration %d (%s): %v", m.version, m.name, err)
+			}
+		}
+		tx := db.Begin()
+		if err := tx.Error; err != nil {
+			return err
+		}
+		if !m.batched {
+			if err := m.up(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s): %v", m.version, m.name, err)
+			}
+		}
+		err := tx.Create(&schemaMigration{
+			Version:   m.version,
+			Name:      m.name,
+			AppliedAt: time.Now(),
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %v", m.version, m.name, err)
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"version": m.version,
+			"name":    m.name,
+		}).Info("Schema migration has been applied")
+	}
+	return nil
+}
+
+// renameLegacy keeps a cache table created by AutoMigrate of older versions
+// under another name so its records can be copied
+func renameLegacy(tx *gorm.DB) error {
+	if tx.HasTable(legacyTable) || !tx.HasTable(cacheTable) {
+		// the table is already renamed or there is no legacy table
+		return nil
+	}
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %v RENAME TO %v", cacheTable, legacyTable)).Error; err != nil {
+		return err
+	}
+	if tx.Dialect().GetName() == "postgres" {
+		// an index of a primary key keeps its name, it would clash with the one of a new table
+		return tx.Exec(fmt.Sprintf("ALTER INDEX IF EXISTS %v_pkey RENAME TO %v_pkey", cacheTable, legacyTable)).Error
+	}
+	return nil
+}
+
+func createCache(tx *gorm.DB) error {
+	if tx.HasTable(cacheTable) {
+		return nil
+	}
+	return tx.CreateTable(&Cache{}).Error
+}
+
+// requestIndex is an index of requests for a prefix filter of List
+const requestIndex = "idx_caches_request"
+
+// indexRequest creates an index of requests which a left anchored LIKE of every dialect can use.
+// MySQL indexes a prefix of a text column, PostgreSQL needs an operator class of a pattern match
+// unless a database has the C collation.
+func indexRequest(tx *gorm.DB) error {
+	if tx.Dialect().HasIndex(cacheTable, requestIndex) {
+		return nil
+	}
+	column := "request"
+	switch tx.Dialect().GetName() {
+	case "mysql":
+		column = "request(255)"
+	case "postgres":
+		column = "request text_pattern_ops"
+	}
+	return tx.Exec(fmt.Sprintf("CREATE INDEX %v ON %v (%v)", requestIndex, cacheTable, column)).Error
+}
+
+// legacyCache is a record of a legacy cache table.
+// Columns of older versions could be missing, then they are zero.
+type legacyCache struct {
+	Request      string
+	Responce     []byte
+	ResStatus    int
+	RefreshDate  time.Time
+	RequestDate  time.Time
+	AskCount     int
+	ETag         string
+	LastModified string
+	ExpireDate   time.Time
+	Encoding     string
+	Header       string
+}
+
+// copyLegacy copies records of a legacy cache table to the cache table as they are.
+// Records are read by keyset pagination on the primary key and every batch is copied
+// in its own transaction, so a large table is neither rescanned nor locked for long.
+// Records copied by an interrupted run are skipped. Keys of legacy records are not rewritten,
+// `srcctl cache refresh` saves them under current keys and the janitor deletes the old ones.
+func copyLegacy(db *gorm.DB) error {
+	if !db.HasTable(legacyTable) {
+		return nil
+	}
+	columns := []string{}
+	for _, c := range []string{"request", "responce", "res_status", "refresh_date", "request_date",
+		"ask_count", "e_tag", "last_modified", "expire_date", "encoding", "header"} {
+		if db.Dialect().HasColumn(legacyTable, c) {
+			columns = append(columns, c)
+		}
+	}
+
+	copied := 0
+	last := ""
+	for {
+		batch := []legacyCache{}
+		err := db.Table(legacyTable).Select(strings.Join(columns, ", ")).
+			Where("request > ?", last).Order("request").Limit(copyBatch).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		n, err := copyBatchOf(db, batch)
+		if err != nil {
+			return err
+		}
+		copied += n
+		last = batch[len(batch)-1].Request
+		if len(batch) < copyBatch {
+			break
+		}
+	}
+	log.WithFields(log.Fields{
+		"records": copied,
+		"table":   legacyTable,
+	}).Info("Legacy cache records have been copied")
+	return nil
+}
+
+// copyBatchOf copies legacy records which are not copied yet in one transaction
+func copyBatchOf(db *gorm.DB, batch []legacyCache) (int, error) {
+	hashes := make([]string, len(batch))
+	for i, l := range batch {
+		hashes[i] = hashKey(l.Request)
+	}
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
+		return 0, err
+	}
+	copied := []string{}
+	if err := tx.Model(&Cache{}).Where("key_hash IN (?)", hashes).Pluck("key_hash", &copied).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	skip := map[string]bool{}
+	for _, h := range copied {
+		skip[h] = true
+	}
+	n := 0
+	for i, l := range batch {
+		if skip[hashes[i]] {
+			continue
+		}
+		c := Cache{
+			KeyHash:      hashes[i],
+			Request:      l.Request,
+			Responce:     l.Responce,
+			ResStatus:    l.ResStatus,
+			RefreshDate:  l.RefreshDate,
+			RequestDate:  l.RequestDate,
+			AskCount:     l.AskCount,
+			ETag:         l.ETag,
+			LastModified: l.LastModified,
+			ExpireDate:   l.ExpireDate,
+			Encoding:     l.Encoding,
+			Header:       l.Header,
+		}
+		if err := tx.Create(&c).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		n++
+	}
+	return n, tx.Commit().Error
+}
@@ -0,0 +1,135 @@
+package inmem
+
+import (
+	"container/heap"
+	"container/list"
+
+	service "simpleRestCache/pkg/service"
+)
+
+// Eviction policies
+const (
+	LRU = "lru" // evicts the least recently used record
+	LFU = "lfu" // evicts the least frequently used record by AskCount
+)
+
+// entry is a cache record with its bookkeeping
+type entry struct {
+	c    service.Cache
+	size int64
+
+	elem  *list.Element // position in lru list
+	index int           // position in lfu heap
+}
+
+// sizeOf returns an approximate size of a cache record in bytes
+func sizeOf(c service.Cache) int64 {
+	size := len(c.Request) + len(c.Responce) + len(c.ETag) + len(c.LastModified)
+	for k, v := range c.Header {
+		size += len(k) + len(v)
+	}
+	return int64(size)
+}
+
+// evictor chooses records for eviction
+type evictor interface {
+	add(e *entry)
+	touch(e *entry)
+	remove(e *entry)
+	// victim returns a record for eviction other than exclude if it is possible
+	victim(exclude *entry) *entry
+}
+
+func newEvictor(policy string) evictor {
+	if policy == LFU {
+		return &lfu{}
+	}
+	return &lru{list: list.New()}
+}
+
+// lru keeps records in order of usage. The front is the most recently used.
+type lru struct {
+	list *list.List
+}
+
+func (l *lru) add(e *entry) {
+	e.elem = l.list.PushFront(e)
+}
+
+func (l *lru) touch(e *entry) {
+	l.list.MoveToFront(e.elem)
+}
+
+func (l *lru) remove(e *entry) {
+	l.list.Remove(e.elem)
+}
+
+func (l *lru) victim(exclude *entry) *entry {
+	for el := l.list.Back(); el != nil; el = el.Prev() {
+		if e := el.Value.(*entry); e != exclude {
+			return e
+		}
+	}
+	return exclude
+}
+
+// lfu is a min-heap of records by AskCount.
+// Records with the same AskCount are ordered by RequestDate.
+type lfu []*entry
+
+func (h lfu) Len() int { return len(h) }
+
+func (h lfu) Less(i, j int) bool {
+	if h[i].c.AskCount != h[j].c.AskCount {
+		return h[i].c.AskCount < h[j].c.AskCount
+	}
+	return h[i].c.RequestDate.Before(h[j].c.RequestDate)
+}
+
+func (h lfu) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfu) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfu) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+func (h *lfu) add(e *entry) {
+	heap.Push(h, e)
+}
+
+func (h *lfu) touch(e *entry) {
+	heap.Fix(h, e.index)
+}
+
+func (h *lfu) remove(e *entry) {
+	heap.Remove(h, e.index)
+}
+
+func (h *lfu) victim(exclude *entry) *entry {
+	if len(*h) == 0 {
+		return nil
+	}
+	if (*h)[0] != exclude || len(*h) == 1 {
+		return (*h)[0]
+	}
+	// the second minimum is one of the children of the root
+	v := (*h)[1]
+	if len(*h) > 2 && h.Less(2, 1) {
+		v = (*h)[2]
+	}
+	return v
+}
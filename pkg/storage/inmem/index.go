@@ -0,0 +1,77 @@
+package inmem
+
+import "math/rand"
+
+// maxIndexLevel bounds levels of the skip list, it is enough for 4^16 records
+const maxIndexLevel = 16
+
+// keyIndex is a skip list of requests in sorted order, List walks it from a cursor.
+// Insert and remove cost O(log N), so the index is kept up to date under the storage lock.
+type keyIndex struct {
+	head  indexNode // a sentinel before the first key
+	level int
+	rnd   *rand.Rand
+}
+
+type indexNode struct {
+	key  string
+	next []*indexNode
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		head:  indexNode{next: make([]*indexNode, maxIndexLevel)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// path returns the last node before key on every level
+func (x *keyIndex) path(key string) [maxIndexLevel]*indexNode {
+	var update [maxIndexLevel]*indexNode
+	n := &x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key < key {
+			n = n.next[i]
+		}
+		update[i] = n
+	}
+	return update
+}
+
+// insert adds a key which is not in the index
+func (x *keyIndex) insert(key string) {
+	update := x.path(key)
+	level := 1
+	for level < maxIndexLevel && x.rnd.Intn(4) == 0 {
+		level++
+	}
+	for ; x.level < level; x.level++ {
+		update[x.level] = &x.head
+	}
+	n := &indexNode{key: key, next: make([]*indexNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+}
+
+// remove deletes a key if it is in the index
+func (x *keyIndex) remove(key string) {
+	update := x.path(key)
+	n := update[0].next[0]
+	if n == nil || n.key != key {
+		return
+	}
+	for i := range n.next {
+		update[i].next[i] = n.next[i]
+	}
+	for x.level > 1 && x.head.next[x.level-1] == nil {
+		x.level--
+	}
+}
+
+// seek returns the node of the first key which is not less then key, nil if there is none
+func (x *keyIndex) seek(key string) *indexNode {
+	return x.path(key)[0].next[0]
+}
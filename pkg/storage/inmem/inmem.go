@@ -1,7 +1,10 @@
 package inmem
 
 import (
+	"context"
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,88 +14,218 @@ import (
 	service "simpleRestCache/pkg/service"
 )
 
-// Storage stores objects in memory
+// Storage stores objects in memory.
+// When a number of records or their size exceeds limits
+// records are evicted according to an eviction policy.
 type Storage struct {
-	cache map[string]service.Cache
+	cache map[string]*entry
+	index *keyIndex // requests of cache in order for List
 	sync.RWMutex
+
+	evictor    evictor
+	policy     string
+	maxEntries int   // 0 means unlimited
+	maxBytes   int64 // 0 means unlimited
+	bytes      int64
+	evictions  int64
+
+	// readTouch is set if reads change an order of eviction, it is LRU with limits.
+	// Reads hold s.RLock, so they touch the evictor under touchMu.
+	readTouch bool
+	touchMu   sync.Mutex
+
+	snapshot *snapshotter // nil if snapshots are disabled
 }
 
 // New returns a storage object
 func New(cfg *config.Config) *Storage {
-	s := &Storage{
-		cache: make(map[string]service.Cache),
+	s := newStorage(cfg.InmemMaxEntries, cfg.InmemMaxBytes, cfg.InmemEviction)
+	log.WithFields(log.Fields{
+		"maxEntries": s.maxEntries,
+		"maxBytes":   s.maxBytes,
+		"eviction":   s.policy,
+	}).Info("Storage subsystem has been initialized")
+	if cfg.InmemSnapshot != "" {
+		loadSnapshot(cfg.InmemSnapshot, func(c service.Cache) {
+			s.Put(context.Background(), c)
+		})
+		s.snapshot = newSnapshotter(cfg.InmemSnapshot, cfg.InmemSnapshotInterval, s.records)
 	}
-	log.Info("Storage subsystem has been initialized")
 	return s
 }
 
-// Close is an empty function for closing inmem storage
+func newStorage(maxEntries int, maxBytes int64, policy string) *Storage {
+	return &Storage{
+		cache:      make(map[string]*entry),
+		index:      newKeyIndex(),
+		evictor:    newEvictor(policy),
+		policy:     policy,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		readTouch:  policy != LFU && (maxEntries > 0 || maxBytes > 0),
+	}
+}
+
+// Close writes a snapshot if snapshots are enabled
 func (s *Storage) Close() {
+	if s.snapshot != nil {
+		s.snapshot.Close()
+	}
 	log.Info("Storage subsystem has been closed")
 }
 
+// records returns copies of all records
+func (s *Storage) records() []service.Cache {
+	s.RLock()
+	defer s.RUnlock()
+
+	r := make([]service.Cache, 0, len(s.cache))
+	for _, e := range s.cache {
+		r = append(r, e.c)
+	}
+	return r
+}
+
 // Cache returns cache for a requested string
-func (s *Storage) Cache(r string) service.Cache {
+func (s *Storage) Cache(ctx context.Context, r string) (service.Cache, error) {
 	s.RLock()
 	defer s.RUnlock()
 
-	_, ok := s.cache[r]
+	e, ok := s.cache[r]
 	if !ok {
-		return service.Cache{Err: service.ErrCacheNotFound}
+		return service.Cache{}, service.ErrCacheNotFound
+	}
+	if s.readTouch {
+		s.touchMu.Lock()
+		s.evictor.touch(e)
+		s.touchMu.Unlock()
 	}
-	return s.cache[r]
+	return e.c, nil
 }
 
 // SaveCache saves record to cache
-func (s *Storage) SaveCache(c service.Cache) {
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
 	s.Lock()
 	defer s.Unlock()
 
-	if v, ok := s.cache[c.Request]; ok {
-		// if a record is exist update save RequestDate and AskCount
-		s.cache[c.Request] = service.Cache{
-			Request:     c.Request,
-			Responce:    c.Responce,
-			ResStatus:   c.ResStatus,
-			RefreshDate: time.Now(),
-			RequestDate: v.RequestDate,
-			AskCount:    v.AskCount,
-		}
+	r := service.Cache{
+		Request:      c.Request,
+		Responce:     c.Responce,
+		ResStatus:    c.ResStatus,
+		RefreshDate:  time.Now(),
+		ETag:         c.ETag,
+		LastModified: c.LastModified,
+		ExpireDate:   c.ExpireDate,
+		Encoding:     c.Encoding,
+		Header:       c.Header,
+	}
+	if e, ok := s.cache[c.Request]; ok {
+		// if a record is exist save RequestDate and AskCount
+		r.RequestDate = e.c.RequestDate
+		r.AskCount = e.c.AskCount
+	}
+	s.put(r)
+	return nil
+}
+
+// Put stores a record as is, e.g. a record read from other storage
+func (s *Storage) Put(ctx context.Context, c service.Cache) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.put(c)
+	return nil
+}
+
+// put stores a record and evicts other records if it is needed. s.Lock must be held.
+func (s *Storage) put(c service.Cache) {
+	e, ok := s.cache[c.Request]
+	if ok {
+		s.bytes -= e.size
+		e.c = c
+		e.size = sizeOf(c)
+		s.bytes += e.size
+		s.evictor.touch(e)
 	} else {
-		// if new then add a new record
-		s.cache[c.Request] = service.Cache{
-			Request:     c.Request,
-			Responce:    c.Responce,
-			ResStatus:   c.ResStatus,
-			RefreshDate: time.Now(),
+		e = &entry{
+			c:    c,
+			size: sizeOf(c),
 		}
+		s.cache[c.Request] = e
+		s.index.insert(c.Request)
+		s.bytes += e.size
+		s.evictor.add(e)
 	}
+	s.evict(e)
+}
+
+// evict removes records until the storage fits its limits.
+// The just saved record is evicted only if it alone exceeds the limits.
+// s.Lock must be held.
+func (s *Storage) evict(saved *entry) {
+	if s.maxBytes > 0 && saved.size > s.maxBytes {
+		// it is useless to evict other records for it
+		s.remove(saved)
+		s.evictions++
+		log.WithFields(log.Fields{
+			"query":    saved.c.Request,
+			"size":     saved.size,
+			"maxBytes": s.maxBytes,
+		}).Warn("Record is larger then the storage and has not been saved")
+		return
+	}
+	for s.overflow() {
+		e := s.evictor.victim(saved)
+		if e == nil {
+			return
+		}
+		s.remove(e)
+		s.evictions++
+		log.WithFields(log.Fields{
+			"query":    e.c.Request,
+			"size":     e.size,
+			"count":    e.c.AskCount,
+			"eviction": s.policy,
+		}).Debug("Record has been evicted from cache")
+	}
+}
+
+// overflow reports whether the storage exceeds its limits. s.Lock must be held.
+func (s *Storage) overflow() bool {
+	if s.maxEntries > 0 && len(s.cache) > s.maxEntries {
+		return true
+	}
+	return s.maxBytes > 0 && s.bytes > s.maxBytes
+}
+
+// remove deletes a record. s.Lock must be held.
+func (s *Storage) remove(e *entry) {
+	s.evictor.remove(e)
+	delete(s.cache, e.c.Request)
+	s.index.remove(e.c.Request)
+	s.bytes -= e.size
 }
 
 // UpdateStat updates statistic of a partitional cache record
-func (s *Storage) UpdateStat(req service.Request) {
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
 	s.Lock()
 	defer s.Unlock()
 
-	c := s.cache[req.Q]
-	ac := c.AskCount + 1
-	s.cache[req.Q] = service.Cache{
-		Request:     c.Request,
-		Responce:    c.Responce,
-		ResStatus:   c.ResStatus,
-		RefreshDate: c.RefreshDate,
-		RequestDate: time.Now(),
-		AskCount:    ac,
+	e, ok := s.cache[st.Request]
+	if !ok {
+		// a record could be evicted or was not saved at all
+		return nil
 	}
-	log.WithFields(log.Fields{
-		"id":    req.ID,
-		"query": req.Q,
-		"count": ac,
-	}).Info("View count and request date fields has been increased ")
+	if st.RequestDate.After(e.c.RequestDate) {
+		e.c.RequestDate = st.RequestDate
+	}
+	e.c.AskCount += st.Count
+	s.evictor.touch(e)
+	return nil
 }
 
 // TopN returns N most visited request from cache
-func (s *Storage) TopN(n int) ([]service.Cache, error) {
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
 	s.RLock()
 	defer s.RUnlock()
 
@@ -109,7 +242,7 @@ func (s *Storage) TopN(n int) ([]service.Cache, error) {
 
 	var ss []kv
 	for k, v := range s.cache {
-		ss = append(ss, kv{k, v.AskCount})
+		ss = append(ss, kv{k, v.c.AskCount})
 	}
 
 	sort.Slice(ss, func(i, j int) bool {
@@ -117,13 +250,13 @@ func (s *Storage) TopN(n int) ([]service.Cache, error) {
 	})
 
 	for _, kv := range ss[:n] {
-		r = append(r, s.cache[kv.key])
+		r = append(r, s.cache[kv.key].c)
 	}
 	return r, nil
 }
 
 // LastN returns N most unvisited request from cache
-func (s *Storage) LastN(n int) ([]service.Cache, error) {
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
 	s.RLock()
 	defer s.RUnlock()
 
@@ -140,7 +273,7 @@ func (s *Storage) LastN(n int) ([]service.Cache, error) {
 
 	var ss []kv
 	for k, v := range s.cache {
-		ss = append(ss, kv{k, v.AskCount})
+		ss = append(ss, kv{k, v.c.AskCount})
 	}
 
 	sort.Slice(ss, func(i, j int) bool {
@@ -148,29 +281,140 @@ func (s *Storage) LastN(n int) ([]service.Cache, error) {
 	})
 
 	for _, kv := range ss[:n] {
-		r = append(r, s.cache[kv.key])
+		r = append(r, s.cache[kv.key].c)
 	}
 	return r, nil
 }
 
-// All returns all cache records
-func (s *Storage) All() ([]service.Cache, error) {
+// List returns a page of records in order of requests, a cursor is the last listed request
+// Records are walked in order of the index from the cursor or the prefix,
+// so a page costs only records which are read for it.
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
 	s.RLock()
 	defer s.RUnlock()
 
+	now := time.Now()
 	r := []service.Cache{}
+	start := f.Prefix
+	if cursor > start {
+		start = cursor
+	}
+	for n := s.index.seek(start); n != nil && strings.HasPrefix(n.key, f.Prefix); n = n.next[0] {
+		if n.key == cursor {
+			continue
+		}
+		e := s.cache[n.key]
+		if !f.Match(e.c, now) {
+			continue
+		}
+		if len(r) == limit {
+			// following records could match, the next page starts after the last listed one
+			return omitBody(r, f), r[len(r)-1].Request, nil
+		}
+		r = append(r, e.c)
+	}
+	return omitBody(r, f), "", nil
+}
 
-	for _, c := range s.cache {
-		r = append(r, c)
+// page returns first limit records sorted by requests and a cursor of the next page.
+// more reports whether there are records after the given ones.
+func page(r []service.Cache, limit int, more bool) ([]service.Cache, string) {
+	if len(r) > limit {
+		r = r[:limit]
 	}
-	return r, nil
+	if !more || len(r) == 0 {
+		return r, ""
+	}
+	return r, r[len(r)-1].Request
+}
+
+// omitBody empties responces of listed records if a filter asks it
+func omitBody(r []service.Cache, f service.Filter) []service.Cache {
+	if !f.OmitBody {
+		return r
+	}
+	for i := range r {
+		r[i].Responce = ""
+	}
+	return r
 }
 
 // Clean deletes all cache records
-func (s *Storage) Clean() error {
+func (s *Storage) Clean(ctx context.Context) error {
 	s.Lock()
 	defer s.Unlock()
 
-	s.cache = make(map[string]service.Cache)
+	s.cache = make(map[string]*entry)
+	s.index = newKeyIndex()
+	s.evictor = newEvictor(s.policy)
+	s.bytes = 0
 	return nil
 }
+
+// Purge deletes at most limit records which were neither requested nor refreshed since before
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	n := 0
+	for _, e := range s.cache {
+		if n >= limit {
+			break
+		}
+		if e.c.RequestDate.Before(before) && e.c.RefreshDate.Before(before) {
+			s.remove(e)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Stats returns a statistic of the storage
+func (s *Storage) Stats() []string {
+	r := []string{}
+	r = append(r, fmt.Sprintf("%v<->%v", "Storage", "inmem"))
+	r = append(r, fmt.Sprintf("%v<->%v", "Eviction", s.policy))
+	return append(r, s.stat().strings()...)
+}
+
+// stat is a statistic of a storage
+type stat struct {
+	entries    int
+	maxEntries int
+	bytes      int64
+	maxBytes   int64
+	evictions  int64
+}
+
+func (s *Storage) stat() stat {
+	s.RLock()
+	defer s.RUnlock()
+
+	return stat{
+		entries:    len(s.cache),
+		maxEntries: s.maxEntries,
+		bytes:      s.bytes,
+		maxBytes:   s.maxBytes,
+		evictions:  s.evictions,
+	}
+}
+
+func (st stat) add(o stat) stat {
+	return stat{
+		entries:    st.entries + o.entries,
+		maxEntries: st.maxEntries + o.maxEntries,
+		bytes:      st.bytes + o.bytes,
+		maxBytes:   st.maxBytes + o.maxBytes,
+		evictions:  st.evictions + o.evictions,
+	}
+}
+
+func (st stat) strings() []string {
+	r := []string{}
+	r = append(r, fmt.Sprintf("%v<->%v", "Entries", st.entries))
+	r = append(r, fmt.Sprintf("%v<->%v", "MaxEntries", st.maxEntries))
+	r = append(r, fmt.Sprintf("%v<->%v", "Bytes", st.bytes))
+	r = append(r, fmt.Sprintf("%v<->%v", "MaxBytes", st.maxBytes))
+	r = append(r, fmt.Sprintf("%v<->%v", "Evictions", st.evictions))
+	return r
+}
@@ -0,0 +1,92 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		s := New(&config.Config{})
+		return s, s.Close
+	})
+}
+
+func TestSharded(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		s := NewSharded(&config.Config{InmemShards: 4})
+		return s, s.Close
+	})
+}
+
+func TestReadsKeepLRUOrder(t *testing.T) {
+	s := New(&config.Config{InmemMaxEntries: 3})
+	defer s.Close()
+	ctx := context.Background()
+	storagetest.Save(t, s, "/a", "/b", "/c")
+
+	// concurrent reads share the lock and still touch records
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, r := range []string{"/a", "/c"} {
+				if _, err := s.Cache(ctx, r); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	storagetest.Save(t, s, "/d")
+	if _, err := s.Cache(ctx, "/b"); err != service.ErrCacheNotFound {
+		t.Errorf("the least recently read record is not evicted: %v", err)
+	}
+	for _, r := range []string{"/a", "/c", "/d"} {
+		if _, err := s.Cache(ctx, r); err != nil {
+			t.Errorf("%v: %v", r, err)
+		}
+	}
+}
+
+func TestPerShard(t *testing.T) {
+	for _, tc := range []struct {
+		limit int64
+		n     int
+		want  []int64
+	}{
+		{0, 3, []int64{0, 0, 0}},
+		{10, 4, []int64{3, 3, 2, 2}},
+		{10, 16, []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{9, 3, []int64{3, 3, 3}},
+	} {
+		for i, want := range tc.want {
+			if got := perShard(tc.limit, tc.n, i); got != want {
+				t.Errorf("perShard(%d, %d, %d) = %d, want %d", tc.limit, tc.n, i, got, want)
+			}
+		}
+	}
+}
+
+func TestShardedMaxEntries(t *testing.T) {
+	s := NewSharded(&config.Config{InmemShards: 4, InmemMaxEntries: 10})
+	defer s.Close()
+	for i := 0; i < 200; i++ {
+		storagetest.Save(t, s, fmt.Sprintf("/%d", i))
+	}
+	cache, _, err := s.List(context.Background(), "", 100, service.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 10 {
+		t.Errorf("%d records in a storage of 10", len(cache))
+	}
+}
@@ -0,0 +1,215 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+)
+
+// Sharded stores objects in memory in several shards.
+// A record belongs to a shard by a hash of its request key,
+// so requests to different keys do not contend for one lock.
+// Limits of the storage are divided between shards, shares differ by one at most
+// and add up to the limits.
+type Sharded struct {
+	shards []*Storage
+
+	snapshot *snapshotter // nil if snapshots are disabled
+}
+
+// NewSharded returns a sharded storage object
+func NewSharded(cfg *config.Config) *Sharded {
+	n := cfg.InmemShards
+	if n < 1 {
+		n = 1
+	}
+	s := &Sharded{
+		shards: make([]*Storage, n),
+	}
+	for i := range s.shards {
+		s.shards[i] = newStorage(int(perShard(int64(cfg.InmemMaxEntries), n, i)), perShard(cfg.InmemMaxBytes, n, i), cfg.InmemEviction)
+	}
+	log.WithFields(log.Fields{
+		"shards":     n,
+		"maxEntries": cfg.InmemMaxEntries,
+		"maxBytes":   cfg.InmemMaxBytes,
+		"eviction":   cfg.InmemEviction,
+	}).Info("Storage subsystem has been initialized")
+	if cfg.InmemSnapshot != "" {
+		loadSnapshot(cfg.InmemSnapshot, func(c service.Cache) {
+			s.Put(context.Background(), c)
+		})
+		s.snapshot = newSnapshotter(cfg.InmemSnapshot, cfg.InmemSnapshotInterval, s.records)
+	}
+	return s
+}
+
+// perShard returns a share of a limit of shard i of n, 0 means unlimited.
+// The first limit % n shards get one more, a share of a limit is never 0.
+func perShard(limit int64, n, i int) int64 {
+	if limit == 0 {
+		return 0
+	}
+	share := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		share++
+	}
+	if share == 0 {
+		return 1
+	}
+	return share
+}
+
+// shard returns a shard of a request key
+func (s *Sharded) shard(key string) *Storage {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Close writes a snapshot of all shards if snapshots are enabled
+func (s *Sharded) Close() {
+	if s.snapshot != nil {
+		s.snapshot.Close()
+	}
+	log.Info("Storage subsystem has been closed")
+}
+
+// records returns copies of records of all shards
+func (s *Sharded) records() []service.Cache {
+	r := []service.Cache{}
+	for _, sh := range s.shards {
+		r = append(r, sh.records()...)
+	}
+	return r
+}
+
+// Cache returns cache for a requested string
+func (s *Sharded) Cache(ctx context.Context, r string) (service.Cache, error) {
+	return s.shard(r).Cache(ctx, r)
+}
+
+// SaveCache saves record to cache
+func (s *Sharded) SaveCache(ctx context.Context, c service.Cache) error {
+	return s.shard(c.Request).SaveCache(ctx, c)
+}
+
+// Put stores a record as is, e.g. a record read from other storage
+func (s *Sharded) Put(ctx context.Context, c service.Cache) error {
+	return s.shard(c.Request).Put(ctx, c)
+}
+
+// UpdateStat updates statistic of a partitional cache record
+func (s *Sharded) UpdateStat(ctx context.Context, st service.Stat) error {
+	return s.shard(st.Request).UpdateStat(ctx, st)
+}
+
+// TopN returns N most visited request from cache.
+// Top N of every shard are merged.
+func (s *Sharded) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	r := []service.Cache{}
+	for _, sh := range s.shards {
+		c, err := sh.TopN(ctx, n)
+		if err != nil {
+			return []service.Cache{}, err
+		}
+		r = append(r, c...)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].AskCount > r[j].AskCount
+	})
+
+	if n < len(r) {
+		r = r[:n]
+	}
+	return r, nil
+}
+
+// LastN returns N most unvisited request from cache.
+// Last N of every shard are merged.
+func (s *Sharded) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	r := []service.Cache{}
+	for _, sh := range s.shards {
+		c, err := sh.LastN(ctx, n)
+		if err != nil {
+			return []service.Cache{}, err
+		}
+		r = append(r, c...)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].AskCount < r[j].AskCount
+	})
+
+	if n < len(r) {
+		r = r[:n]
+	}
+	return r, nil
+}
+
+// List returns a page of records of all shards in order of requests
+func (s *Sharded) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	r := []service.Cache{}
+	more := false
+	for _, sh := range s.shards {
+		c, next, err := sh.List(ctx, cursor, limit, f)
+		if err != nil {
+			return []service.Cache{}, "", err
+		}
+		r = append(r, c...)
+		more = more || next != ""
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Request < r[j].Request
+	})
+	r, next := page(r, limit, more || len(r) > limit)
+	return r, next, nil
+}
+
+// Clean deletes all cache records
+func (s *Sharded) Clean(ctx context.Context) error {
+	for _, sh := range s.shards {
+		if err := sh.Clean(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge deletes at most limit records which were neither requested nor refreshed since before
+func (s *Sharded) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	n := 0
+	for _, sh := range s.shards {
+		if n >= limit {
+			break
+		}
+		d, err := sh.Purge(ctx, before, limit-n)
+		n += d
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Stats returns a statistic of the storage summed over shards
+func (s *Sharded) Stats() []string {
+	st := stat{}
+	for _, sh := range s.shards {
+		st = st.add(sh.stat())
+	}
+
+	r := []string{}
+	r = append(r, fmt.Sprintf("%v<->%v", "Storage", "inmem"))
+	r = append(r, fmt.Sprintf("%v<->%v", "Eviction", s.shards[0].policy))
+	r = append(r, fmt.Sprintf("%v<->%v", "Shards", len(s.shards)))
+	return append(r, st.strings()...)
+}
@@ -0,0 +1,184 @@
+package inmem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	service "simpleRestCache/pkg/service"
+)
+
+// A snapshot file is a header and gob encoded records:
+// 8 bytes of snapshotMagic, a big endian uint32 version, a big endian uint64 length of records,
+// records and a big endian uint32 CRC-32 (IEEE) of records.
+const (
+	snapshotMagic   = "SRCINMEM"
+	snapshotVersion = 1
+	snapshotHeader  = len(snapshotMagic) + 4 + 8
+)
+
+// errSnapshotCorrupt arise when a snapshot file is truncated or damaged
+var errSnapshotCorrupt = errors.New("Snapshot is corrupt")
+
+// writeSnapshot writes records to a file. The file is replaced atomically
+// so a crash during writing keeps the previous snapshot.
+func writeSnapshot(path string, records []service.Cache) (int, error) {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(records); err != nil {
+		return 0, err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(snapshotMagic)
+	binary.Write(w, binary.BigEndian, uint32(snapshotVersion))
+	binary.Write(w, binary.BigEndian, uint64(body.Len()))
+	w.Write(body.Bytes())
+	binary.Write(w, binary.BigEndian, crc32.ChecksumIEEE(body.Bytes()))
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return snapshotHeader + body.Len() + 4, os.Rename(tmp, path)
+}
+
+// readSnapshot reads records from a file and checks its integrity
+func readSnapshot(path string) ([]service.Cache, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < snapshotHeader || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, errSnapshotCorrupt
+	}
+	version := binary.BigEndian.Uint32(data[len(snapshotMagic):])
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("unknown version %d of a snapshot", version)
+	}
+	size := binary.BigEndian.Uint64(data[len(snapshotMagic)+4:])
+	if rest := uint64(len(data) - snapshotHeader); size > rest || rest-size != 4 {
+		return nil, errSnapshotCorrupt
+	}
+	body := data[snapshotHeader : snapshotHeader+int(size)]
+	if binary.BigEndian.Uint32(data[snapshotHeader+int(size):]) != crc32.ChecksumIEEE(body) {
+		return nil, errSnapshotCorrupt
+	}
+
+	records := []service.Cache{}
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&records); err != nil {
+		return nil, errSnapshotCorrupt
+	}
+	return records, nil
+}
+
+// loadSnapshot puts records of a snapshot file to a storage.
+// A missing file is an empty cache, a corrupt one is skipped.
+func loadSnapshot(path string, put func(c service.Cache)) {
+	records, err := readSnapshot(path)
+	if os.IsNotExist(err) {
+		log.WithFields(log.Fields{
+			"file": path,
+		}).Info("There is no snapshot, storage starts empty")
+		return
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file": path,
+			"err":  err,
+		}).Warn("Cannot read a snapshot, storage starts empty")
+		return
+	}
+	for _, c := range records {
+		put(c)
+	}
+	log.WithFields(log.Fields{
+		"file":    path,
+		"records": len(records),
+	}).Info("Storage has been restored from a snapshot")
+}
+
+// snapshotter writes snapshots of a storage on every interval and on Close
+type snapshotter struct {
+	path     string
+	interval time.Duration // 0 means only on Close
+	records  func() []service.Cache
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSnapshotter(path string, interval time.Duration, records func() []service.Cache) *snapshotter {
+	s := &snapshotter{
+		path:     path,
+		interval: interval,
+		records:  records,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *snapshotter) run() {
+	defer close(s.done)
+	if s.interval == 0 {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.write()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// write writes a snapshot of current records
+func (s *snapshotter) write() {
+	records := s.records()
+	size, err := writeSnapshot(s.path, records)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file": s.path,
+			"err":  err,
+		}).Error("Cannot write a snapshot")
+		return
+	}
+	log.WithFields(log.Fields{
+		"file":    s.path,
+		"records": len(records),
+		"bytes":   size,
+	}).Info("Snapshot has been written")
+}
+
+// Close stops periodic snapshots and writes the last one
+func (s *snapshotter) Close() {
+	close(s.stop)
+	<-s.done
+	s.write()
+}
@@ -0,0 +1,145 @@
+package inmem
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+func TestReadSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcsnapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []service.Cache{
+		{Request: "/a", Responce: "body", ResStatus: 200, RefreshDate: date, RequestDate: date, AskCount: 3,
+			Header: map[string]string{"Content-Type": "application/json"}},
+		{Request: "/b", Responce: string([]byte{0x1f, 0x8b, 0xff}), ResStatus: 404, Encoding: "gzip"},
+	}
+	path := filepath.Join(dir, "snapshot")
+	size, err := writeSnapshot(path, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(valid) {
+		t.Errorf("written %d bytes, file has %d", size, len(valid))
+	}
+
+	for _, tc := range []struct {
+		name    string
+		damage  func(data []byte) []byte
+		wantErr bool
+	}{
+		{"round trip", func(data []byte) []byte { return data }, false},
+		{"empty file", func(data []byte) []byte { return nil }, true},
+		{"truncated header", func(data []byte) []byte { return data[:snapshotHeader-1] }, true},
+		{"truncated records", func(data []byte) []byte { return data[:len(data)-10] }, true},
+		{"truncated checksum", func(data []byte) []byte { return data[:len(data)-1] }, true},
+		{"trailing bytes", func(data []byte) []byte { return append(data, 0) }, true},
+		{"huge length", func(data []byte) []byte {
+			binary.BigEndian.PutUint64(data[len(snapshotMagic)+4:], 1<<63)
+			return data
+		}, true},
+		{"bad checksum", func(data []byte) []byte {
+			data[snapshotHeader] ^= 0xff
+			return data
+		}, true},
+		{"wrong magic", func(data []byte) []byte {
+			data[0] = 'X'
+			return data
+		}, true},
+		{"wrong version", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[len(snapshotMagic):], snapshotVersion+1)
+			return data
+		}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.damage(append([]byte{}, valid...))
+			file := filepath.Join(dir, "damaged")
+			if err := ioutil.WriteFile(file, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readSnapshot(file)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("a damaged snapshot is read: %+v", got)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(records) || got[0].Request != "/a" || got[0].AskCount != 3 || !got[0].RequestDate.Equal(date) ||
+					got[0].Header["Content-Type"] != "application/json" || got[1].Responce != records[1].Responce || got[1].Encoding != "gzip" {
+					t.Errorf("read records %+v", got)
+				}
+			}
+
+			// a storage skips a snapshot which cannot be read and starts empty
+			s := New(&config.Config{InmemSnapshot: file})
+			defer s.Close()
+			cache, _, err := s.List(context.Background(), "", 100, service.Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{}
+			if !tc.wantErr {
+				want = []string{"/a", "/b"}
+			}
+			if got := storagetest.Requests(cache); !storagetest.Equal(got, want) {
+				t.Errorf("records of a started storage %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "srcsnapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{InmemSnapshot: filepath.Join(dir, "snapshot"), InmemShards: 4}
+	ctx := context.Background()
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := NewSharded(cfg)
+	storagetest.Save(t, s, "/a", "/b", "/c")
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/b", Count: 2, RequestDate: date}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// a restarted storage has records with their statistic
+	s = NewSharded(cfg)
+	defer s.Close()
+	cache, _, err := s.List(ctx, "", 100, service.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := storagetest.Requests(cache); !storagetest.Equal(got, []string{"/a", "/b", "/c"}) {
+		t.Errorf("restored records %v", got)
+	}
+	c, err := s.Cache(ctx, "/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AskCount != 2 || !c.RequestDate.Equal(date) || c.Responce != "body /b" {
+		t.Errorf("restored record %+v", c)
+	}
+}
@@ -0,0 +1,416 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+)
+
+const (
+	// keyPrefix is a prefix of hashes of cache records
+	keyPrefix = "src:cache:"
+	// askCountKey is a sorted set of requests scored by AskCount
+	askCountKey = "src:askcount"
+	// dateKey is a sorted set of requests scored by the latest of RequestDate and RefreshDate
+	// in milliseconds, Purge reads the oldest records from it
+	dateKey = "src:date"
+	// scanCount is a hint of a number of keys returned by one SCAN
+	scanCount = 100
+)
+
+// saveScript saves a record and keeps its statistic.
+// A new record gets zero AskCount in the hash and in the sorted set.
+// KEYS[1] - a hash of a record, KEYS[2] - the AskCount sorted set, KEYS[3] - the date sorted set,
+// ARGV[1] - a key TTL in milliseconds, ARGV[2] - a request, ARGV[3] - a refresh date in milliseconds,
+// ARGV[4:] - fields and values.
+var saveScript = goredis.NewScript(`
+local fresh = redis.call('EXISTS', KEYS[1]) == 0
+redis.call('HMSET', KEYS[1], unpack(ARGV, 4))
+if fresh then
+	redis.call('HSET', KEYS[1], 'askCount', 0)
+	redis.call('ZADD', KEYS[2], 0, ARGV[2])
+end
+local date = redis.call('ZSCORE', KEYS[3], ARGV[2])
+if not date or tonumber(date) < tonumber(ARGV[3]) then
+	redis.call('ZADD', KEYS[3], ARGV[3], ARGV[2])
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// statScript increases AskCount and sets RequestDate of an existing record.
+// KEYS[1] - a hash of a record, KEYS[2] - the AskCount sorted set, KEYS[3] - the date sorted set,
+// ARGV[1] - a request date, ARGV[2] - a request, ARGV[3] - an increment of AskCount,
+// ARGV[4] - a request date in milliseconds.
+// It returns a new AskCount or -1 if there is no record.
+var statScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local count = redis.call('HINCRBY', KEYS[1], 'askCount', ARGV[3])
+redis.call('HSET', KEYS[1], 'requestDate', ARGV[1])
+redis.call('ZADD', KEYS[2], count, ARGV[2])
+local date = redis.call('ZSCORE', KEYS[3], ARGV[2])
+if not date or tonumber(date) < tonumber(ARGV[4]) then
+	redis.call('ZADD', KEYS[3], ARGV[4], ARGV[2])
+end
+return count
+`)
+
+// purgeScript deletes at most a limit of the oldest records and their requests in sorted sets.
+// Requests of records expired by TTL are removed too, but they are not counted.
+// KEYS[1] - the date sorted set, KEYS[2] - the AskCount sorted set,
+// ARGV[1] - a date in milliseconds, ARGV[2] - a limit, ARGV[3] - a prefix of hashes.
+// It returns a number of deleted records.
+var purgeScript = goredis.NewScript(`
+local reqs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[1], 'LIMIT', 0, ARGV[2])
+local n = 0
+for _, r in ipairs(reqs) do
+	n = n + redis.call('DEL', ARGV[3] .. r)
+	redis.call('ZREM', KEYS[1], r)
+	redis.call('ZREM', KEYS[2], r)
+end
+return n
+`)
+
+// Storage stores objects in Redis.
+// Every record is a hash with a native TTL.
+// AskCount of records is duplicated in a sorted set for TopN and LastN,
+// dates of records are duplicated in a sorted set for Purge.
+type Storage struct {
+	client *goredis.Client
+	ttl    time.Duration
+}
+
+// New returns a storage object
+func New(cfg *config.Config) *Storage {
+	opt, err := goredis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		// config validates the URL so it should not happen
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Wrong URL of Redis")
+		opt = &goredis.Options{}
+	}
+	s := &Storage{
+		client: goredis.NewClient(opt),
+		ttl:    cfg.RedisKeyTTL,
+	}
+	if err := s.client.Ping().Err(); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while connected to Redis")
+	} else if err := indexDates(s.client); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot index dates of records")
+	}
+	log.Info("Storage subsystem has been initialized")
+	return s
+}
+
+// Close closes a connection to Redis
+func (s *Storage) Close() {
+	s.client.Close()
+	log.Info("Storage subsystem has been closed")
+}
+
+func key(r string) string {
+	return keyPrefix + r
+}
+
+// Cache returns cache for a requested string
+func (s *Storage) Cache(ctx context.Context, r string) (service.Cache, error) {
+	m, err := s.client.WithContext(ctx).HGetAll(key(r)).Result()
+	if err != nil {
+		return service.Cache{}, err
+	}
+	if len(m) == 0 {
+		return service.Cache{}, service.ErrCacheNotFound
+	}
+	return fromHash(m), nil
+}
+
+// SaveCache saves record to cache
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
+	now := time.Now()
+	args := []interface{}{int64(s.ttl / time.Millisecond), c.Request, millis(now)}
+	for k, v := range map[string]string{
+		"request":      c.Request,
+		"responce":     c.Responce,
+		"resStatus":    strconv.Itoa(c.ResStatus),
+		"refreshDate":  formatTime(now),
+		"etag":         c.ETag,
+		"lastModified": c.LastModified,
+		"expireDate":   formatTime(c.ExpireDate),
+		"encoding":     c.Encoding,
+		"header":       service.EncodeHeader(c.Header),
+	} {
+		args = append(args, k, v)
+	}
+
+	return saveScript.Run(s.client.WithContext(ctx), []string{key(c.Request), askCountKey, dateKey}, args...).Err()
+}
+
+// UpdateStat updates statistic of a partitional cache record
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
+	// a record could be expired or was not saved at all, then nothing is updated
+	return statScript.Run(s.client.WithContext(ctx), []string{key(st.Request), askCountKey, dateKey},
+		formatTime(st.RequestDate), st.Request, st.Count, millis(st.RequestDate)).Err()
+}
+
+// TopN returns N most visited requests from cache
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	c := s.client.WithContext(ctx)
+	return rangeN(c, n, c.ZRevRange)
+}
+
+// LastN returns N most unvisited requests from cache
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	c := s.client.WithContext(ctx)
+	return rangeN(c, n, c.ZRange)
+}
+
+// rangeN reads N records in order of the sorted set.
+// Requests of expired records are removed from the sorted set on the way.
+func rangeN(c *goredis.Client, n int, zrange func(key string, start, stop int64) *goredis.StringSliceCmd) ([]service.Cache, error) {
+	result := []service.Cache{}
+	for start := int64(0); len(result) < n; {
+		reqs, err := zrange(askCountKey, start, start+int64(n-len(result))-1).Result()
+		if err != nil {
+			return []service.Cache{}, err
+		}
+		if len(reqs) == 0 {
+			break
+		}
+		cache, expired, err := records(c, reqs)
+		if err != nil {
+			return []service.Cache{}, err
+		}
+		result = append(result, cache...)
+		start += int64(len(reqs) - len(expired))
+		if len(expired) != 0 {
+			if err := c.ZRem(askCountKey, expired...).Err(); err != nil {
+				return []service.Cache{}, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// records reads records of requests in one round trip.
+// It returns requests without records separately.
+func records(c *goredis.Client, reqs []string) ([]service.Cache, []interface{}, error) {
+	pipe := c.Pipeline()
+	cmds := make([]*goredis.StringStringMapCmd, len(reqs))
+	for i, r := range reqs {
+		cmds[i] = pipe.HGetAll(key(r))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, nil, err
+	}
+
+	cache := []service.Cache{}
+	expired := []interface{}{}
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			expired = append(expired, reqs[i])
+			continue
+		}
+		cache = append(cache, fromHash(cmd.Val()))
+	}
+	return cache, expired, nil
+}
+
+// List returns a page of records, a cursor is a cursor of SCAN.
+// SCAN returns keys by batches which cannot be split, so a page can be a bit larger then limit.
+// A prefix of requests is matched by Redis, other filters are applied to read records.
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	var next uint64
+	if cursor != "" {
+		var err error
+		if next, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return []service.Cache{}, "", fmt.Errorf("wrong cursor %q: %v", cursor, err)
+		}
+	}
+
+	c := s.client.WithContext(ctx)
+	now := time.Now()
+	result := []service.Cache{}
+	for {
+		keys, n, err := c.Scan(next, keyPrefix+globEscape(f.Prefix)+"*", int64(limit)).Result()
+		if err != nil {
+			return []service.Cache{}, "", err
+		}
+		next = n
+		if len(keys) != 0 {
+			reqs := make([]string, len(keys))
+			for i, k := range keys {
+				reqs[i] = k[len(keyPrefix):]
+			}
+			cache, _, err := records(c, reqs)
+			if err != nil {
+				return []service.Cache{}, "", err
+			}
+			for _, r := range cache {
+				if !f.Match(r, now) {
+					continue
+				}
+				if f.OmitBody {
+					r.Responce = ""
+				}
+				result = append(result, r)
+			}
+		}
+		if next == 0 {
+			return result, "", nil
+		}
+		if len(result) >= limit {
+			return result, strconv.FormatUint(next, 10), nil
+		}
+	}
+}
+
+// globEscape escapes special characters of a glob-style pattern
+func globEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+	return r.Replace(s)
+}
+
+// Clean deletes all cache records
+func (s *Storage) Clean(ctx context.Context) error {
+	c := s.client.WithContext(ctx)
+	err := scan(c, func(keys []string) error {
+		return c.Del(keys...).Err()
+	})
+	if err != nil {
+		return err
+	}
+	return c.Del(askCountKey, dateKey).Err()
+}
+
+// Purge deletes at most limit records which were neither requested nor refreshed since before.
+// The oldest records are read from the date sorted set, so a call costs O(log N + limit).
+// Records which are older then the key TTL are already expired by Redis.
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	n, err := purgeScript.Run(s.client.WithContext(ctx), []string{dateKey, askCountKey},
+		millis(before), limit, keyPrefix).Int()
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// indexDates adds records of older versions to the date sorted set.
+// It runs once, when the sorted set is missing while there is statistic of records.
+func indexDates(c *goredis.Client) error {
+	dates, err := c.Exists(dateKey).Result()
+	if err != nil {
+		return err
+	}
+	stats, err := c.Exists(askCountKey).Result()
+	if err != nil {
+		return err
+	}
+	if dates == 1 || stats == 0 {
+		return nil
+	}
+	indexed := 0
+	err = scan(c, func(keys []string) error {
+		pipe := c.Pipeline()
+		cmds := make([]*goredis.SliceCmd, len(keys))
+		for i, k := range keys {
+			cmds[i] = pipe.HMGet(k, "requestDate", "refreshDate")
+		}
+		if _, err := pipe.Exec(); err != nil {
+			return err
+		}
+		members := []goredis.Z{}
+		for i, cmd := range cmds {
+			v := cmd.Val()
+			requestDate, _ := v[0].(string)
+			refreshDate, _ := v[1].(string)
+			date := parseTime(refreshDate)
+			if t := parseTime(requestDate); t.After(date) {
+				date = t
+			}
+			members = append(members, goredis.Z{Score: float64(millis(date)), Member: keys[i][len(keyPrefix):]})
+		}
+		indexed += len(members)
+		return c.ZAdd(dateKey, members...).Err()
+	})
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"records": indexed,
+	}).Info("Dates of records have been indexed")
+	return nil
+}
+
+// scan calls fn for every batch of keys of records
+func scan(c *goredis.Client, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(cursor, keyPrefix+"*", scanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) != 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// fromHash converts a hash of a record to service.Cache
+func fromHash(m map[string]string) service.Cache {
+	status, _ := strconv.Atoi(m["resStatus"])
+	count, _ := strconv.Atoi(m["askCount"])
+	return service.Cache{
+		Request:      m["request"],
+		Responce:     m["responce"],
+		ResStatus:    status,
+		RefreshDate:  parseTime(m["refreshDate"]),
+		RequestDate:  parseTime(m["requestDate"]),
+		AskCount:     count,
+		ETag:         m["etag"],
+		LastModified: m["lastModified"],
+		ExpireDate:   parseTime(m["expireDate"]),
+		Encoding:     m["encoding"],
+		Header:       service.DecodeHeader(m["header"]),
+	}
+}
+
+// millis returns a score of a date in a sorted set, a zero time is 0
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseTime parses a time of a hash field, a missing field is a zero time
+func parseTime(v string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, v)
+	return t
+}
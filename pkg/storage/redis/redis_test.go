@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+// newTestStorage returns a storage connected to an in-process Redis and a func which stops them
+func newTestStorage(t *testing.T, ttl time.Duration) (*Storage, *miniredis.Miniredis, func()) {
+	t.Helper()
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(&config.Config{RedisURL: "redis://" + m.Addr(), RedisKeyTTL: ttl})
+	return s, m, func() {
+		s.Close()
+		m.Close()
+	}
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		s, _, stop := newTestStorage(t, 0)
+		return s, stop
+	})
+}
+
+func TestTopNSkipsExpired(t *testing.T) {
+	s, m, stop := newTestStorage(t, 0)
+	defer stop()
+	ctx := context.Background()
+	storagetest.Save(t, s, "/a", "/b", "/c", "/d")
+	for r, n := range map[string]int{"/a": 1, "/b": 2, "/c": 3, "/d": 4} {
+		if err := s.UpdateStat(ctx, service.Stat{Request: r, Count: n, RequestDate: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an expired record is skipped and removed from the sorted set
+	m.Del(key("/d"))
+	top, err := s.TopN(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := storagetest.Requests(top); !storagetest.Equal(got, []string{"/c", "/b"}) {
+		t.Errorf("TopN after expiration %v", got)
+	}
+	members, err := m.ZMembers(askCountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := members; !storagetest.Equal(got, []string{"/a", "/b", "/c"}) {
+		t.Errorf("sorted set after expiration %v", got)
+	}
+}
+
+func TestPurgeSortedSet(t *testing.T) {
+	s, m, stop := newTestStorage(t, 0)
+	defer stop()
+	ctx := context.Background()
+	storagetest.Save(t, s, "/a", "/b", "/c", "/d", "/e", "/live")
+	before := time.Now().Add(time.Minute)
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/live", Count: 1, RequestDate: before.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a record expired by TTL is removed from sorted sets but is not counted
+	m.Del(key("/e"))
+	n, err := s.Purge(ctx, before, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("Purge deleted %d, want 4", n)
+	}
+	for _, k := range []string{askCountKey, dateKey} {
+		members, err := m.ZMembers(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !storagetest.Equal(members, []string{"/live"}) {
+			t.Errorf("sorted set %v after Purge %v", k, members)
+		}
+	}
+}
+
+func TestIndexDates(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// a record of an older version has no date in the sorted set
+	date := time.Now().Add(-time.Hour)
+	m.HSet(key("/old"), "request", "/old")
+	m.HSet(key("/old"), "refreshDate", formatTime(date))
+	m.HSet(key("/old"), "requestDate", formatTime(date.Add(time.Minute)))
+	m.ZAdd(askCountKey, 0, "/old")
+
+	s := New(&config.Config{RedisURL: "redis://" + m.Addr()})
+	defer s.Close()
+	score, err := m.ZScore(dateKey, "/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := float64(millis(date.Add(time.Minute))); score != want {
+		t.Errorf("date of an indexed record %v, want %v", score, want)
+	}
+	n, err := s.Purge(context.Background(), time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || m.Exists(key("/old")) {
+		t.Errorf("an indexed record is not purged: %d", n)
+	}
+}
+
+func TestKeyTTL(t *testing.T) {
+	s, m, stop := newTestStorage(t, time.Hour)
+	defer stop()
+	ctx := context.Background()
+	storagetest.Save(t, s, "/a")
+
+	if ttl := m.TTL(key("/a")); ttl != time.Hour {
+		t.Errorf("TTL %v, want %v", ttl, time.Hour)
+	}
+	// a statistic update does not extend a TTL, a save does
+	m.FastForward(30 * time.Minute)
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/a", Count: 1, RequestDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := m.TTL(key("/a")); ttl != 30*time.Minute {
+		t.Errorf("TTL after UpdateStat %v, want %v", ttl, 30*time.Minute)
+	}
+	storagetest.Save(t, s, "/a")
+	if ttl := m.TTL(key("/a")); ttl != time.Hour {
+		t.Errorf("TTL after SaveCache %v, want %v", ttl, time.Hour)
+	}
+
+	m.FastForward(2 * time.Hour)
+	if _, err := s.Cache(ctx, "/a"); err != service.ErrCacheNotFound {
+		t.Errorf("expired record: got %v, want %v", err, service.ErrCacheNotFound)
+	}
+
+	s0, m0, stop0 := newTestStorage(t, 0)
+	defer stop0()
+	storagetest.Save(t, s0, "/a")
+	if ttl := m0.TTL(key("/a")); ttl != 0 {
+		t.Errorf("TTL of a storage without TTL %v", ttl)
+	}
+}
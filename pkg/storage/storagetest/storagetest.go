@@ -0,0 +1,321 @@
+// Package storagetest is a suite of tests which every service.Storage runs
+package storagetest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	service "simpleRestCache/pkg/service"
+)
+
+// NewStorage returns an empty storage and a func which closes it
+type NewStorage func(t *testing.T) (service.Storage, func())
+
+// Run runs the suite against storages of newStorage, every test gets a new storage
+func Run(t *testing.T, newStorage NewStorage) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, s service.Storage)
+	}{
+		{"SaveCache", testSaveCache},
+		{"UpdateStat", testUpdateStat},
+		{"TopNLastN", testTopNLastN},
+		{"List", testList},
+		{"Purge", testPurge},
+		{"Clean", testClean},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, stop := newStorage(t)
+			defer stop()
+			tc.test(t, s)
+		})
+	}
+}
+
+// Save saves records of requests with a body "body <request>" and status 200
+func Save(t *testing.T, s service.Storage, reqs ...string) {
+	t.Helper()
+	for _, r := range reqs {
+		if err := s.SaveCache(context.Background(), service.Cache{Request: r, Responce: "body " + r, ResStatus: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Requests returns requests of records in order
+func Requests(cache []service.Cache) []string {
+	r := []string{}
+	for _, c := range cache {
+		r = append(r, c.Request)
+	}
+	return r
+}
+
+// Equal reports whether a and b have the same strings in the same order
+func Equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// listAll reads all pages of a listing by limit records
+func listAll(t *testing.T, s service.Storage, limit int, f service.Filter) []service.Cache {
+	t.Helper()
+	all := []service.Cache{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("listing does not end")
+		}
+		cache, next, err := s.List(context.Background(), cursor, limit, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, cache...)
+		if next == "" {
+			return all
+		}
+		cursor = next
+	}
+}
+
+// testSaveCache checks that a save overwrites a record and keeps its statistic
+func testSaveCache(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	long := "/a?q=" + strings.Repeat("x", 2000)
+	body := string([]byte{0x1f, 0x8b, 0x00, 0xff, 0xfe})
+
+	Save(t, s, long)
+	if err := s.UpdateStat(ctx, service.Stat{Request: long, Count: 3, RequestDate: date}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.SaveCache(ctx, service.Cache{Request: long, Responce: body, ResStatus: 404, Encoding: "gzip",
+		Header: map[string]string{"Content-Type": "text/plain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := s.Cache(ctx, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Request != long || c.Responce != body || c.ResStatus != 404 || c.Encoding != "gzip" || c.Header["Content-Type"] != "text/plain" {
+		t.Errorf("record is not overwritten: %+v", c)
+	}
+	if c.AskCount != 3 || !c.RequestDate.Equal(date) {
+		t.Errorf("statistic is not kept: AskCount %d, RequestDate %v", c.AskCount, c.RequestDate)
+	}
+	if time.Since(c.RefreshDate) > time.Minute {
+		t.Errorf("RefreshDate is not set: %v", c.RefreshDate)
+	}
+	if _, err := s.Cache(ctx, "/b"); err != service.ErrCacheNotFound {
+		t.Errorf("missing record: got %v, want %v", err, service.ErrCacheNotFound)
+	}
+}
+
+// testUpdateStat checks that concurrent updates are not lost and are seen by TopN and LastN
+func testUpdateStat(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	Save(t, s, "/a", "/b")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.UpdateStat(ctx, service.Stat{Request: "/a", Count: 2, RequestDate: time.Now()}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := s.Cache(ctx, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AskCount != 20 {
+		t.Errorf("AskCount %d, want 20", c.AskCount)
+	}
+	top, err := s.TopN(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Request != "/a" || top[0].AskCount != 20 {
+		t.Errorf("TopN after UpdateStat %+v", top)
+	}
+	last, err := s.LastN(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Requests(last); !Equal(got, []string{"/b", "/a"}) {
+		t.Errorf("LastN after UpdateStat %v", got)
+	}
+
+	// statistic of a missing record does not create it
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/c", Count: 1, RequestDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cache(ctx, "/c"); err != service.ErrCacheNotFound {
+		t.Errorf("record of a missing request: got %v, want %v", err, service.ErrCacheNotFound)
+	}
+	top, err = s.TopN(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 {
+		t.Errorf("TopN has a missing request %v", Requests(top))
+	}
+}
+
+// testTopNLastN checks orders of TopN by AskCount and LastN by RequestDate
+func testTopNLastN(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	Save(t, s, "/a", "/b", "/c", "/d")
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for r, n := range map[string]int{"/a": 1, "/b": 2, "/c": 3, "/d": 4} {
+		st := service.Stat{Request: r, Count: n, RequestDate: date.Add(time.Duration(n) * time.Second)}
+		if err := s.UpdateStat(ctx, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top, err := s.TopN(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Requests(top); !Equal(got, []string{"/d", "/c"}) {
+		t.Errorf("TopN %v", got)
+	}
+	last, err := s.LastN(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Requests(last); !Equal(got, []string{"/a", "/b"}) {
+		t.Errorf("LastN %v", got)
+	}
+
+	// a save keeps a record in its place
+	Save(t, s, "/d")
+	top, err = s.TopN(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Requests(top); !Equal(got, []string{"/d", "/c", "/b", "/a"}) {
+		t.Errorf("TopN after SaveCache %v", got)
+	}
+}
+
+// testList checks filters and paging of List, a prefix has characters of LIKE and glob patterns
+func testList(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	Save(t, s, "/a%b?x=1", "/a%b?x=2", "/aXb?x=1", "/a_b?x=1", "/a*b?x=1", "/a[b]?x=1", "/ab?x=1", "/c?x=1")
+	if err := s.SaveCache(ctx, service.Cache{Request: "/a%b?x=3", ResStatus: 404}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, c := range listAll(t, s, 1, service.Filter{Prefix: "/a%b", Status: 200, OmitBody: true}) {
+		if c.Responce != "" {
+			t.Errorf("body of %q is not omitted", c.Request)
+		}
+		if got[c.Request] {
+			t.Errorf("%q is listed twice", c.Request)
+		}
+		got[c.Request] = true
+	}
+	if len(got) != 2 || !got["/a%b?x=1"] || !got["/a%b?x=2"] {
+		t.Errorf("List %v", got)
+	}
+
+	for _, prefix := range []string{"/a_b", "/a*b", "/a[b]"} {
+		cache, next, err := s.List(ctx, "", 100, service.Filter{Prefix: prefix})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := prefix + "?x=1"
+		if got := Requests(cache); !Equal(got, []string{want}) || cache[0].Responce != "body "+want || next != "" {
+			t.Errorf("List of %q %v, next %q", prefix, got, next)
+		}
+	}
+
+	if all := listAll(t, s, 3, service.Filter{MaxAge: time.Hour}); len(all) != 9 {
+		t.Errorf("List of all records %v", Requests(all))
+	}
+	if old := listAll(t, s, 3, service.Filter{MinAge: time.Hour}); len(old) != 0 {
+		t.Errorf("List of old records %v", Requests(old))
+	}
+}
+
+// testPurge checks that Purge deletes at most limit records which are not requested
+func testPurge(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	Save(t, s, "/a", "/b", "/c", "/d", "/e", "/live")
+	before := time.Now().Add(time.Minute)
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/live", Count: 1, RequestDate: before.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []int{2, 2, 1, 0} {
+		n, err := s.Purge(ctx, before, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("Purge deleted %d, want %d", n, want)
+		}
+	}
+	if got := Requests(listAll(t, s, 100, service.Filter{})); !Equal(got, []string{"/live"}) {
+		t.Errorf("records after Purge %v", got)
+	}
+	for _, f := range []func(context.Context, int) ([]service.Cache, error){s.TopN, s.LastN} {
+		cache, err := f(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Requests(cache); !Equal(got, []string{"/live"}) {
+			t.Errorf("statistic after Purge %v", got)
+		}
+	}
+}
+
+// testClean checks that Clean deletes all records and their statistic
+func testClean(t *testing.T, s service.Storage) {
+	ctx := context.Background()
+	Save(t, s, "/a", "/b")
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/a", Count: 1, RequestDate: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clean(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cache(ctx, "/a"); err != service.ErrCacheNotFound {
+		t.Errorf("record after Clean: got %v, want %v", err, service.ErrCacheNotFound)
+	}
+	if all := listAll(t, s, 100, service.Filter{}); len(all) != 0 {
+		t.Errorf("List after Clean %v", Requests(all))
+	}
+	top, err := s.TopN(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 0 {
+		t.Errorf("TopN after Clean %v", Requests(top))
+	}
+
+	// a storage is usable after Clean
+	Save(t, s, "/c")
+	if _, err := s.Cache(ctx, "/c"); err != nil {
+		t.Errorf("record saved after Clean: %v", err)
+	}
+}
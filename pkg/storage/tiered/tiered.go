@@ -0,0 +1,179 @@
+package tiered
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	service "simpleRestCache/pkg/service"
+)
+
+// Backend is a storage which can be a tier
+type Backend interface {
+	service.Storage
+	Close()
+}
+
+// Front is a fast storage for the first tier.
+// Put stores a record as is, it is used to copy records from the second tier.
+type Front interface {
+	Backend
+	Put(ctx context.Context, c service.Cache) error
+}
+
+// Storage is a two-tier storage. A small fast L1 is in front of L2.
+// Reads go through L1 to L2 and records found in L2 are copied to L1.
+// Writes go to L2 and then to L1. TopN, LastN and List are answered by L2
+// because L1 holds only a part of records. A record of L1 is a copy of L2 with
+// AskCount, RequestDate and RefreshDate, and both tiers get the same statistic updates,
+// so L1 serves what `srcctl stat` and `srcctl cache list` show.
+type Storage struct {
+	l1 Front
+	l2 Backend
+
+	l1Hits int64
+	l2Hits int64
+	misses int64
+}
+
+// New returns a two-tier storage object
+func New(l1 Front, l2 Backend) *Storage {
+	log.Info("Two-tier storage subsystem has been initialized")
+	return &Storage{
+		l1: l1,
+		l2: l2,
+	}
+}
+
+// Close closes both tiers
+func (s *Storage) Close() {
+	s.l1.Close()
+	s.l2.Close()
+}
+
+// Cache returns cache for a requested string
+func (s *Storage) Cache(ctx context.Context, r string) (service.Cache, error) {
+	c, err := s.l1.Cache(ctx, r)
+	if err == nil {
+		atomic.AddInt64(&s.l1Hits, 1)
+		log.WithFields(log.Fields{
+			"query": r,
+			"tier":  "L1",
+		}).Info("Cache record is served by a tier")
+		return c, nil
+	}
+	if err != service.ErrCacheNotFound {
+		log.WithFields(log.Fields{
+			"query": r,
+			"err":   err,
+		}).Warn("Cannot read a record from L1")
+	}
+
+	c, err = s.l2.Cache(ctx, r)
+	if err != nil {
+		if err == service.ErrCacheNotFound {
+			atomic.AddInt64(&s.misses, 1)
+		}
+		return service.Cache{}, err
+	}
+	atomic.AddInt64(&s.l2Hits, 1)
+	log.WithFields(log.Fields{
+		"query": r,
+		"tier":  "L2",
+	}).Info("Cache record is served by a tier")
+
+	if err := s.l1.Put(ctx, c); err != nil {
+		log.WithFields(log.Fields{
+			"query": r,
+			"err":   err,
+		}).Warn("Cannot copy a record to L1")
+	}
+	return c, nil
+}
+
+// SaveCache saves record to L2 and copies a saved record with its statistic to L1
+func (s *Storage) SaveCache(ctx context.Context, c service.Cache) error {
+	if err := s.l2.SaveCache(ctx, c); err != nil {
+		return err
+	}
+	saved, err := s.l2.Cache(ctx, c.Request)
+	if err != nil {
+		// a record could expire at once or L2 fails, L1 keeps its own statistic then
+		log.WithFields(log.Fields{
+			"query": c.Request,
+			"err":   err,
+		}).Warn("Cannot read a saved record from L2")
+		return s.l1.SaveCache(ctx, c)
+	}
+	return s.l1.Put(ctx, saved)
+}
+
+// UpdateStat updates statistic of a record in both tiers.
+// L1 needs it for an eviction policy.
+func (s *Storage) UpdateStat(ctx context.Context, st service.Stat) error {
+	if err := s.l2.UpdateStat(ctx, st); err != nil {
+		return err
+	}
+	return s.l1.UpdateStat(ctx, st)
+}
+
+// TopN returns N most visited requests from L2
+func (s *Storage) TopN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.l2.TopN(ctx, n)
+}
+
+// LastN returns N most unvisited requests from L2
+func (s *Storage) LastN(ctx context.Context, n int) ([]service.Cache, error) {
+	return s.l2.LastN(ctx, n)
+}
+
+// List returns a page of records from L2
+func (s *Storage) List(ctx context.Context, cursor string, limit int, f service.Filter) ([]service.Cache, string, error) {
+	return s.l2.List(ctx, cursor, limit, f)
+}
+
+// Clean deletes all cache records in both tiers
+func (s *Storage) Clean(ctx context.Context) error {
+	if err := s.l2.Clean(ctx); err != nil {
+		return err
+	}
+	return s.l1.Clean(ctx)
+}
+
+// Purge deletes dead records in both tiers, it returns a number of records deleted in L2
+func (s *Storage) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	n, err := s.l2.Purge(ctx, before, limit)
+	if err != nil {
+		return n, err
+	}
+	if _, err := s.l1.Purge(ctx, before, limit); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Stats returns hits of every tier and statistic of tiers if they report it
+func (s *Storage) Stats() []string {
+	r := []string{}
+	r = append(r, fmt.Sprintf("%v<->%v", "Storage", "tiered"))
+	r = append(r, fmt.Sprintf("%v<->%v", "L1Hits", atomic.LoadInt64(&s.l1Hits)))
+	r = append(r, fmt.Sprintf("%v<->%v", "L2Hits", atomic.LoadInt64(&s.l2Hits)))
+	r = append(r, fmt.Sprintf("%v<->%v", "Misses", atomic.LoadInt64(&s.misses)))
+	for _, t := range []struct {
+		name    string
+		storage service.Storage
+	}{
+		{"L1", s.l1},
+		{"L2", s.l2},
+	} {
+		if sr, ok := t.storage.(service.StatsReporter); ok {
+			for _, v := range sr.Stats() {
+				r = append(r, t.name+"."+v)
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"simpleRestCache/pkg/config"
+	service "simpleRestCache/pkg/service"
+	"simpleRestCache/pkg/storage/inmem"
+	"simpleRestCache/pkg/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (service.Storage, func()) {
+		s := New(inmem.New(&config.Config{InmemMaxEntries: 100}), inmem.New(&config.Config{}))
+		return s, s.Close
+	})
+}
+
+func TestL1KeepsStatOfL2(t *testing.T) {
+	l1 := inmem.New(&config.Config{InmemMaxEntries: 1})
+	l2 := inmem.New(&config.Config{})
+	s := New(l1, l2)
+	defer s.Close()
+	ctx := context.Background()
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	storagetest.Save(t, s, "/a", "/b")
+	// "/a" is evicted from L1, its statistic goes to L2 only
+	if err := s.UpdateStat(ctx, service.Stat{Request: "/a", Count: 3, RequestDate: date}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		name string
+		do   func() error
+	}{
+		{"promotion", func() error {
+			_, err := s.Cache(ctx, "/a")
+			return err
+		}},
+		{"save", func() error {
+			return s.SaveCache(ctx, service.Cache{Request: "/a", Responce: "new", ResStatus: 200})
+		}},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatal(err)
+		}
+		c1, err := l1.Cache(ctx, "/a")
+		if err != nil {
+			t.Fatalf("%v: a record is not in L1: %v", step.name, err)
+		}
+		c2, err := l2.Cache(ctx, "/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c1.AskCount != 3 || !c1.RequestDate.Equal(date) || !c1.RefreshDate.Equal(c2.RefreshDate) {
+			t.Errorf("%v: L1 record %+v, L2 record %+v", step.name, c1, c2)
+		}
+	}
+}